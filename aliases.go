@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// readAliases reads a two-column TSV mapping original column names to the
+// names that should be displayed in the output.
+func readAliases(path string) (map[string]string, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open aliases file '%s': %v", path, err)
+	}
+	defer fp.Close()
+	aliases := make(map[string]string)
+	scanner := bufio.NewScanner(fp)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("expected line %d of '%s' to have 2 fields", lineNum, path)
+		}
+		if _, ok := aliases[fields[0]]; ok {
+			return nil, fmt.Errorf("column '%s' is aliased more than once in '%s'", fields[0], path)
+		}
+		aliases[fields[0]] = fields[1]
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// displayNames applies the aliases to the column names. Columns without an
+// alias keep their original names.
+func displayNames(fieldNames []string, aliases map[string]string) []string {
+	names := make([]string, len(fieldNames))
+	for i, name := range fieldNames {
+		if alias, ok := aliases[name]; ok {
+			name = alias
+		}
+		names[i] = name
+	}
+	return names
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// readLogLikelihoods returns the natural log probability of each read's
+// values under the model where every column is independent with its
+// marginal probability.
+func readLogLikelihoods(t *Tally) []float64 {
+	scores := make([]float64, t.NumReads)
+	base := 0.0
+	n := float64(t.NumReads)
+	for i := range t.FieldNames {
+		p := float64(t.Marginals[i]) / n
+		if t.Marginals[i] == 0 || t.Marginals[i] == t.NumReads {
+			// Every read has the same value, which has probability 1
+			continue
+		}
+		base += math.Log(1 - p)
+		delta := math.Log(p) - math.Log(1-p)
+		for r := 0; r < t.NumReads; r++ {
+			if t.Columns[i].get(r) {
+				scores[r] += delta
+			}
+		}
+	}
+	for r := range scores {
+		scores[r] += base
+	}
+	return scores
+}
+
+// percentile returns the smallest value at or above which lie at least pct
+// percent of the values, using the nearest-rank method, and whether there
+// is any such value.
+func percentile(values []float64, pct float64) (float64, bool) {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	k := int(math.Ceil(pct / 100 * float64(len(sorted))))
+	if k <= 0 {
+		return 0, false
+	}
+	return sorted[k-1], true
+}
+
+// writeAnomalies prints the reads whose log-likelihood under independence
+// is in the lowest -anomaly-percentile percent, least likely first.
+func writeAnomalies(w io.Writer, t *Tally, names []string) {
+	scores := readLogLikelihoods(t)
+	threshold, ok := percentile(scores, args.AnomalyPercentile)
+	fmt.Fprintln(w, "read\tloglik")
+	if !ok {
+		return
+	}
+	var outliers []int
+	for r, score := range scores {
+		if score <= threshold {
+			outliers = append(outliers, r)
+		}
+	}
+	sort.SliceStable(outliers, func(a, b int) bool {
+		return scores[outliers[a]] < scores[outliers[b]]
+	})
+	for _, r := range outliers {
+		fmt.Fprintf(w, "%s\t%s\n", t.Reads[r], formatValue(scores[r], 'f', 8))
+	}
+}
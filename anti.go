@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// writeAntiCorrelated prints the negatively correlated pairs, most negative
+// first, followed by their lift and contingency table. Only pairs where both
+// columns are 1 in at least -anti-support reads are considered, since with
+// rare columns a lack of co-occurrence is expected by chance.
+func writeAntiCorrelated(w io.Writer, t *Tally, names []string) {
+	type pair struct {
+		i, j int
+		phi  float64
+	}
+	var pairs []pair
+	for i := range names {
+		for j := i + 1; j < len(names); j++ {
+			if t.Marginals[i] < args.AntiSupport || t.Marginals[j] < args.AntiSupport {
+				continue
+			}
+			phi := mcc(t.Contingency(i, j))
+			if phi < 0 {
+				pairs = append(pairs, pair{i, j, phi})
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(a, b int) bool {
+		return pairs[a].phi < pairs[b].phi
+	})
+	for _, p := range pairs {
+		n11, n10, n01, n00 := t.Contingency(p.i, p.j)
+		fmt.Fprintf(w, "phi( %s , %s ) = %s ; %s ; %d , %d , %d , %d\n",
+			names[p.i], names[p.j], formatValue(p.phi, 'f', 8), formatValue(lift(n11, n10, n01, n00), 'f', 8), n11, n10, n01, n00)
+	}
+}
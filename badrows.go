@@ -0,0 +1,87 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// badRowLog records the lines of wide format input that failed to parse
+// when -strict=false, -skip-bad or -all-errors lets reading go on past them.
+// The first -max-errors errors in them are kept to be reported, one per
+// invalid cell, except with -skip-bad where the bad lines are only counted.
+// It counts the short lines that -pad filled out too.
+type badRowLog struct {
+	errors []error
+	count  int
+	total  int
+	padded int64
+}
+
+var badRows badRowLog
+
+// tolerateMu serializes the recording of bad lines by the readers of the
+// -inputs, which are read all at once.
+var tolerateMu sync.Mutex
+
+// skipping reports whether bad lines are skipped rather than stopping the
+// reading.
+func (l *badRowLog) skipping() bool {
+	return !args.Strict || args.SkipBad || args.AllErrors
+}
+
+// tolerate records a line's parse error and reports whether reading should
+// skip the line and go on, rather than stop at it.
+func (l *badRowLog) tolerate(err error) bool {
+	if !l.skipping() {
+		return false
+	}
+	tolerateMu.Lock()
+	defer tolerateMu.Unlock()
+	l.count++
+	if args.SkipBad {
+		return true
+	}
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	for _, err := range errs {
+		l.total++
+		if len(l.errors) < args.MaxErrors {
+			l.errors = append(l.errors, err)
+		}
+	}
+	return true
+}
+
+// pad counts a line filled out by -pad. It may be called by several parsing
+// goroutines at once.
+func (l *badRowLog) pad() {
+	atomic.AddInt64(&l.padded, 1)
+}
+
+// report logs the errors recorded, and returns the number of bad lines that
+// should make the run fail, which those skipped by -skip-bad don't.
+func (l *badRowLog) report() int {
+	if l.padded > 0 {
+		warnf("padded %d short lines with zeros\n", l.padded)
+	}
+	if args.SkipBad {
+		if l.count > 0 {
+			warnf("skipped %d invalid lines\n", l.count)
+		}
+		return 0
+	}
+	for _, err := range l.errors {
+		errorf("%v\n", err)
+	}
+	if l.total > len(l.errors) {
+		errorf("%d more errors not shown\n", l.total-len(l.errors))
+	}
+	if l.count > 0 && args.AllErrors {
+		errorf("found %d invalid lines\n", l.count)
+	} else if l.count > 0 {
+		errorf("skipped %d invalid lines\n", l.count)
+	}
+	return l.count
+}
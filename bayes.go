@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// betaPosterior returns the parameters of the Beta posterior for the
+// probability of an indicator that was 1 in count of n reads, under a
+// Beta(alpha, beta) prior.
+func betaPosterior(count, n int, alpha, beta float64) (a, b float64) {
+	return float64(count) + alpha, float64(n-count) + beta
+}
+
+// betaMeanVar returns the mean and variance of a Beta(a, b) distribution.
+func betaMeanVar(a, b float64) (mean, variance float64) {
+	mean = a / (a + b)
+	variance = a * b / ((a + b) * (a + b) * (a + b + 1))
+	return mean, variance
+}
+
+// writeBayes prints the posterior mean and variance of each marginal
+// probability under the Beta(-prior-alpha, -prior-beta) prior, followed by
+// the count and the number of reads.
+func writeBayes(w io.Writer, t *Tally, names []string) {
+	for i, name := range names {
+		a, b := betaPosterior(t.Marginals[i], t.NumReads, args.PriorAlpha, args.PriorBeta)
+		mean, variance := betaMeanVar(a, b)
+		fmt.Fprintf(w, "P( %s ) = %s ; %s ; %d , %d\n", name, formatValue(mean, 'f', 6), formatValue(variance, 'f', 8), t.Marginals[i], t.NumReads)
+	}
+}
+
+// writeCredible prints the posterior mean of each marginal probability and
+// its equal-tailed credible interval at -credible-level, followed by the
+// count and the number of reads.
+func writeCredible(w io.Writer, t *Tally, names []string) {
+	tail := (1 - args.CredibleLevel) / 2
+	for i, name := range names {
+		a, b := betaPosterior(t.Marginals[i], t.NumReads, args.PriorAlpha, args.PriorBeta)
+		mean, _ := betaMeanVar(a, b)
+		lower := betaQuantile(tail, a, b)
+		upper := betaQuantile(1-tail, a, b)
+		fmt.Fprintf(w, "P( %s ) = %s ; [ %s , %s ] ; %d , %d\n",
+			name, formatValue(mean, 'f', 6), formatValue(lower, 'f', 6), formatValue(upper, 'f', 6), t.Marginals[i], t.NumReads)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+)
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// generateInput returns a wide format matrix of random values with the
+// given number of reads and columns.
+func generateInput(numReads, numFields int) []byte {
+	var buf bytes.Buffer
+	generateMatrix(&buf, numReads, numFields, 0.5, nil)
+	return buf.Bytes()
+}
+
+// benchmark times parsing the input and tallying its joint counts as two
+// separate phases, and prints the throughput of each as tab-separated
+// 'name, value' lines. The input is generated when -benchmark-reads is
+// given, and read as usual otherwise. The peak memory is the memory the
+// process has obtained from the operating system by the end, which bounds
+// what it ever had in use.
+func benchmark() {
+	var in io.Reader
+	if args.BenchmarkReads > 0 {
+		in = bytes.NewReader(generateInput(args.BenchmarkReads, args.BenchmarkColumns))
+	} else {
+		fp, err := openInput()
+		if err != nil {
+			fatal(err)
+		}
+		defer fp.Close()
+		in = fp
+	}
+	counter := &countingReader{r: in}
+
+	start := time.Now()
+	reader, err := openReader(counter)
+	if err != nil {
+		fatal(err)
+	}
+	numFields := len(reader.Fields())
+	var rows []int
+	numReads := 0
+	for reader.Scan() {
+		rows = append(rows, reader.Row()...)
+		numReads++
+	}
+	if err := reader.Err(); err != nil {
+		fatal(err)
+	}
+	parseSeconds := time.Since(start).Seconds()
+
+	start = time.Now()
+	tally := NewTally(reader.Fields(), true)
+	for r := 0; r < numReads; r++ {
+		tally.Add("", rows[r*numFields:(r+1)*numFields])
+	}
+	jointsSeconds := time.Since(start).Seconds()
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	w := os.Stdout
+	fmt.Fprintf(w, "reads\t%d\n", numReads)
+	fmt.Fprintf(w, "columns\t%d\n", numFields)
+	fmt.Fprintf(w, "bytes\t%d\n", counter.n)
+	fmt.Fprintf(w, "threads\t%d\n", args.Threads)
+	fmt.Fprintf(w, "parse_seconds\t%0.6f\n", parseSeconds)
+	fmt.Fprintf(w, "parse_reads_per_second\t%0.1f\n", float64(numReads)/parseSeconds)
+	fmt.Fprintf(w, "parse_mb_per_second\t%0.3f\n", float64(counter.n)/1e6/parseSeconds)
+	fmt.Fprintf(w, "joints_seconds\t%0.6f\n", jointsSeconds)
+	fmt.Fprintf(w, "joints_reads_per_second\t%0.1f\n", float64(numReads)/jointsSeconds)
+	fmt.Fprintf(w, "peak_memory_bytes\t%d\n", mem.Sys)
+}
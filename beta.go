@@ -0,0 +1,107 @@
+package main
+
+import (
+	"math"
+)
+
+// lbeta returns the log of the beta function B(a, b).
+func lbeta(a, b float64) float64 {
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	return la + lb - lab
+}
+
+// incBeta returns the regularized incomplete beta function I_x(a, b), which
+// is the CDF of a Beta(a, b) distribution at x. It evaluates the continued
+// fraction by the modified Lentz method, using the symmetry
+// I_x(a, b) = 1 - I_{1-x}(b, a) to stay in the region where the fraction
+// converges quickly.
+func incBeta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	if x > (a+1)/(a+b+2) {
+		return 1 - incBeta(1-x, b, a)
+	}
+	front := math.Exp(a*math.Log(x) + b*math.Log1p(-x) - lbeta(a, b) - math.Log(a))
+	return front * betaFraction(x, a, b)
+}
+
+// betaFraction evaluates the continued fraction for the incomplete beta
+// function.
+func betaFraction(x, a, b float64) float64 {
+	const (
+		maxIter = 300
+		epsilon = 1e-15
+		tiny    = 1e-300
+	)
+	c := 1.0
+	d := 1 - (a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m <= maxIter; m++ {
+		fm := float64(m)
+		// The even step of the recurrence
+		num := fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+		// The odd step of the recurrence
+		num = -(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1))
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return h
+}
+
+// betaQuantile returns the x at which the Beta(a, b) CDF equals p. The CDF
+// is monotone, so bisection is slow but always converges. It stops at a
+// relative tolerance, since with a near 0 the quantiles can be far smaller
+// than any absolute one.
+func betaQuantile(p, a, b float64) float64 {
+	if p <= 0 {
+		return 0
+	}
+	if p >= 1 {
+		return 1
+	}
+	lo, hi := 0.0, 1.0
+	for iter := 0; iter < 1100 && hi-lo > 1e-15*hi; iter++ {
+		mid := (lo + hi) / 2
+		if mid == lo || mid == hi {
+			break
+		}
+		if incBeta(mid, a, b) < p {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
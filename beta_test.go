@@ -0,0 +1,53 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// The reference values are R's pbeta and qbeta, from closed forms: for
+// whole a and b, I_x(a, b) is a binomial tail; I_x(a, 1) = x^a and
+// I_x(1, b) = 1 - (1 - x)^b; and I_x(1/2, 1/2) = 2 asin(sqrt(x)) / pi. The
+// first two cover shape parameters near 0, where the density is most
+// skewed.
+
+func TestIncBeta(t *testing.T) {
+	for _, c := range []struct {
+		x, a, b, want float64
+	}{
+		{0, 2, 3, 0},
+		{1, 2, 3, 1},
+		{0.5, 2, 3, 0.6875},
+		{0.3, 5, 7, 0.2103046173},
+		{0.1, 1, 20, 0.8784233454094307},
+		{0.9, 30, 2, 0.1695646331008648},
+		{0.25, 0.5, 0.5, 1.0 / 3},
+		{0.9, 0.5, 0.5, 0.7951672353008666},
+		{0.5, 0.01, 1, 0.9930924954370359},
+		{1e-10, 0.001, 1, 0.9772372209558107},
+		{0.5, 1, 0.001, 0.0006929070095474781},
+	} {
+		if got := incBeta(c.x, c.a, c.b); math.Abs(got-c.want) > 1e-10*c.want {
+			t.Errorf("incBeta(%g, %g, %g) = %.16g, want %.16g", c.x, c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestBetaQuantile(t *testing.T) {
+	for _, c := range []struct {
+		p, a, b, want float64
+	}{
+		{0, 2, 3, 0},
+		{1, 2, 3, 1},
+		{0.6875, 2, 3, 0.5},
+		{0.5, 2, 2, 0.5},
+		{0.025, 0.5, 0.5, 0.001541333133436012},
+		{0.025, 0.5, 1, 0.000625},
+		{0.975, 1, 0.5, 0.999375},
+		{0.5, 0.01, 1, 7.888609052210118e-31},
+	} {
+		if got := betaQuantile(c.p, c.a, c.b); math.Abs(got-c.want) > 1e-9*c.want {
+			t.Errorf("betaQuantile(%g, %g, %g) = %.16g, want %.16g", c.p, c.a, c.b, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import "math/bits"
+
+// A bitset holds one bit per read for a single column.
+type bitset []uint64
+
+func (b bitset) get(i int) bool {
+	return b[i/64]&(1<<uint(i%64)) != 0
+}
+
+func (b bitset) set(i int) {
+	b[i/64] |= 1 << uint(i%64)
+}
+
+// andCount returns the number of reads that are 1 in both columns.
+func andCount(a, b bitset) int {
+	n := 0
+	for k := range a {
+		n += bits.OnesCount64(a[k] & b[k])
+	}
+	return n
+}
+
+// xorCount returns the number of reads where the columns differ.
+func xorCount(a, b bitset) int {
+	n := 0
+	for k := range a {
+		n += bits.OnesCount64(a[k] ^ b[k])
+	}
+	return n
+}
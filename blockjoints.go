@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+)
+
+// writeBlockJoints prints the joints like writeJoints, but without ever
+// holding the whole matrix of joint counts. The columns are split into
+// blocks of -joint-blocks columns, and the counts between each pair of
+// blocks are tallied by another pass over the -input and written before
+// the next pair is started, so only a block's square of counts is held at
+// a time. The pairs come out block by block rather than in column order.
+func writeBlockJoints(w io.Writer, t *Tally, names []string) {
+	size := args.JointBlocks
+	n := len(names)
+	// The bad lines were already counted on the first pass
+	saved := badRows
+	defer func() { badRows = saved }()
+	counts := make([][]int, size)
+	for k := range counts {
+		counts[k] = make([]int, size)
+	}
+	for iStart := 0; iStart < n; iStart += size {
+		for jStart := 0; jStart < n; jStart += size {
+			if args.Triangle && jStart+size <= iStart {
+				continue
+			}
+			iEnd, jEnd := min(iStart+size, n), min(jStart+size, n)
+			for k := range counts {
+				clear(counts[k])
+			}
+			if err := tallyBlock(t, counts, iStart, iEnd, jStart, jEnd); err != nil {
+				fatalf("failed to reread input '%s' for -joint-blocks: %v\n", args.Input, err)
+			}
+			for i := iStart; i < iEnd; i++ {
+				for j := jStart; j < jEnd; j++ {
+					joint := counts[i-iStart][j-jStart]
+					if (args.Triangle && j < i) || (!args.Self && i == j) || (args.SkipZeroJoints && joint == 0) {
+						continue
+					}
+					writeJointLine(w, names[i], names[j], joint, t.NumReads)
+				}
+			}
+		}
+	}
+}
+
+// tallyBlock rereads the input, counting the reads where each column of one
+// block and each column of another are both 1. The columns are taken in
+// the tally's order, which may have been permuted from the input's.
+func tallyBlock(t *Tally, counts [][]int, iStart, iEnd, jStart, jEnd int) error {
+	in, err := openInputFile()
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	reader, err := openReader(in)
+	if err != nil {
+		return err
+	}
+	for reader.Scan() {
+		row := reader.Row()
+		for i := iStart; i < iEnd; i++ {
+			if row[t.Order[i]] == 0 {
+				continue
+			}
+			blockRow := counts[i-iStart]
+			for j := jStart; j < jEnd; j++ {
+				blockRow[j-jStart] += row[t.Order[j]]
+			}
+		}
+	}
+	return reader.Err()
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+)
+
+// checkpointVersion is incremented whenever the checkpoint format changes
+// so that an old checkpoint is never misread.
+const checkpointVersion = 1
+
+// A checkpoint holds the counts tallied from the first Reads reads of the
+// input, so that a run can be resumed after them.
+type checkpoint struct {
+	Version   int             `json:"version"`
+	Fields    []string        `json:"fields"`
+	Reads     int             `json:"reads"`
+	Marginals []int           `json:"marginals"`
+	Joints    [][]int         `json:"joints,omitempty"`
+	JointCols []int           `json:"joint_cols,omitempty"`
+	Sets      []checkpointSet `json:"sets,omitempty"`
+}
+
+type checkpointSet struct {
+	Cols  []int `json:"cols"`
+	Any   int   `json:"any"`
+	All   int   `json:"all"`
+	Given []int `json:"given,omitempty"`
+}
+
+// writeCheckpoint replaces the -checkpoint file with the counts so far.
+func writeCheckpoint(t *Tally, path string) error {
+	cp := checkpoint{
+		Version:   checkpointVersion,
+		Fields:    t.FieldNames,
+		Reads:     t.NumReads,
+		Marginals: t.Marginals,
+		Joints:    t.Joints,
+		JointCols: t.JointCols,
+	}
+	for _, st := range t.sets {
+		cp.Sets = append(cp.Sets, checkpointSet{st.cols, st.any, st.all, st.given})
+	}
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %v", err)
+	}
+	return replaceFile(path, func(w io.Writer) {
+		w.Write(append(data, '\n'))
+	})
+}
+
+// readCheckpoint reads a checkpoint written by writeCheckpoint.
+func readCheckpoint(path string) (*checkpoint, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoint '%s': %v", path, err)
+	}
+	defer fp.Close()
+	var cp checkpoint
+	if err := json.NewDecoder(bufio.NewReader(fp)).Decode(&cp); err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint '%s': %v", path, err)
+	}
+	if cp.Version != checkpointVersion {
+		return nil, fmt.Errorf("checkpoint '%s' has version %d but this version of matrixprobs reads version %d",
+			path, cp.Version, checkpointVersion)
+	}
+	return &cp, nil
+}
+
+// restore replaces the counts in a new tally with those of a checkpoint,
+// checking that the checkpoint was made from the same columns and tallied
+// everything this run needs.
+func (t *Tally) restore(cp *checkpoint) error {
+	if !reflect.DeepEqual(cp.Fields, t.FieldNames) {
+		return fmt.Errorf("the checkpoint was made from input with different columns")
+	}
+	if t.calcJoints && cp.Joints == nil {
+		return fmt.Errorf("the checkpoint has no joint counts, which the outputs need")
+	}
+	if t.calcJoints && !reflect.DeepEqual(cp.JointCols, t.JointCols) {
+		return fmt.Errorf("the checkpoint doesn't count the joints of the same -joint-columns")
+	}
+	if len(cp.Sets) != len(t.sets) {
+		return fmt.Errorf("the checkpoint doesn't track the same -any-of, -all-of and -given sets")
+	}
+	for k, st := range t.sets {
+		if !reflect.DeepEqual(cp.Sets[k].Cols, st.cols) || (cp.Sets[k].Given == nil) != (st.given == nil) {
+			return fmt.Errorf("the checkpoint doesn't track the same -any-of, -all-of and -given sets")
+		}
+		st.any, st.all, st.given = cp.Sets[k].Any, cp.Sets[k].All, cp.Sets[k].Given
+	}
+	t.NumReads = cp.Reads
+	t.Marginals = cp.Marginals
+	if t.calcJoints {
+		t.Joints = cp.Joints
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// chowLiuTree returns the edges of the Chow-Liu tree: the spanning tree
+// over the indicators that maximizes the total mutual information of its
+// edges, found with Kruskal's algorithm. Ties between pairs are broken in
+// column order so the tree is deterministic. When some indicators are
+// independent of all others the result is a forest.
+func chowLiuTree(t *Tally) []edge {
+	n := len(t.FieldNames)
+	var pairs []edge
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			pairs = append(pairs, edge{i, j, t.MutualInformation(i, j)})
+		}
+	}
+	sort.SliceStable(pairs, func(a, b int) bool {
+		return pairs[a].weight > pairs[b].weight
+	})
+	// parent links of a union-find forest of the components joined so far
+	parent := make([]int, n)
+	for i := range parent {
+		parent[i] = i
+	}
+	find := func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	var tree []edge
+	for _, e := range pairs {
+		if len(tree) == n-1 {
+			break
+		}
+		a, b := find(e.from), find(e.to)
+		if a == b || e.weight <= 0 {
+			continue
+		}
+		parent[a] = b
+		tree = append(tree, e)
+	}
+	return tree
+}
+
+// writeChowLiu prints the edges of the Chow-Liu tree with their mutual
+// information, strongest first.
+func writeChowLiu(w io.Writer, t *Tally, names []string) {
+	for _, e := range chowLiuTree(t) {
+		fmt.Fprintf(w, "MI( %s , %s ) = %s\n", names[e.from], names[e.to], formatValue(e.weight, 'f', 8))
+	}
+}
+
+// rootedTree directs the edges of a tree away from the -chow-liu-root, or
+// the first column by default. The other trees of a forest are rooted at
+// their first column. Edges are listed in breadth-first order from the
+// roots.
+func rootedTree(t *Tally, tree []edge) []edge {
+	n := len(t.FieldNames)
+	adjacent := make([][]edge, n)
+	for _, e := range tree {
+		adjacent[e.from] = append(adjacent[e.from], e)
+		adjacent[e.to] = append(adjacent[e.to], edge{e.to, e.from, e.weight})
+	}
+	roots := make([]int, 0, n+1)
+	if args.ChowLiuRoot != "" {
+		cols, err := resolveColumns(args.ChowLiuRoot, t.FieldNames)
+		if err != nil {
+			fatalf("-chow-liu-root: %v\n", err)
+		}
+		roots = append(roots, cols[0])
+	}
+	for i := 0; i < n; i++ {
+		roots = append(roots, i)
+	}
+	visited := make([]bool, n)
+	var directed []edge
+	for _, root := range roots {
+		if visited[root] {
+			continue
+		}
+		visited[root] = true
+		queue := []int{root}
+		for len(queue) > 0 {
+			i := queue[0]
+			queue = queue[1:]
+			for _, e := range adjacent[i] {
+				if !visited[e.to] {
+					visited[e.to] = true
+					directed = append(directed, e)
+					queue = append(queue, e.to)
+				}
+			}
+		}
+	}
+	return directed
+}
+
+// writeChowLiuEdges prints the Chow-Liu tree as an edge list directed away
+// from the root, weighted by mutual information.
+func writeChowLiuEdges(w io.Writer, t *Tally, names []string) {
+	writeEdges(w, names, rootedTree(t, chowLiuTree(t)), true)
+}
+
+// writeChowLiuDot prints the Chow-Liu tree as a Graphviz graph directed away
+// from the root, weighted by mutual information.
+func writeChowLiuDot(w io.Writer, t *Tally, names []string) {
+	writeDotGraph(w, names, rootedTree(t, chowLiuTree(t)), true)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// The distances that columns can be clustered by.
+var clusterDistances = []string{"jaccard", "correlation"}
+
+// A merge joins two clusters during agglomerative clustering. Clusters are
+// numbered like the leaves of a dendrogram: the columns are clusters 0 to
+// n-1, and the cluster formed by the k'th merge is numbered n+k.
+type merge struct {
+	left   int
+	right  int
+	height float64
+	size   int
+}
+
+// columnDistances returns the distance between every pair of columns, either
+// 1 - Jaccard or 1 - |correlation|. Pairs whose similarity is undefined
+// because a column never occurs or is constant are given distance 1.
+func columnDistances(t *Tally, kind string) [][]float64 {
+	n := len(t.FieldNames)
+	dist := make([][]float64, n)
+	for i := range dist {
+		dist[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			var sim float64
+			if kind == "correlation" {
+				sim = math.Abs(mcc(t.Contingency(i, j)))
+			} else {
+				sim = jaccard(t.Contingency(i, j))
+			}
+			d := 1 - sim
+			if math.IsNaN(d) {
+				d = 1
+			}
+			dist[i][j] = d
+			dist[j][i] = d
+		}
+	}
+	return dist
+}
+
+// writeJaccardDistances prints the 1 - Jaccard distance between every pair
+// of columns as a labeled square matrix, for clustering with other tools.
+// As for -cluster-distance jaccard, two columns that never occur are at
+// distance 1 from each other, and every column is at distance 0 from itself.
+func writeJaccardDistances(w io.Writer, t *Tally, names []string) {
+	dist := columnDistances(t, "jaccard")
+	writeMatrix(w, names, func(i, j int) string {
+		return formatValue(dist[i][j], 'f', 8)
+	})
+}
+
+// clusterColumns performs average linkage agglomerative clustering of the
+// columns, returning the n-1 merges in the order they were made. Ties are
+// broken in favour of the lowest numbered clusters so the result is
+// deterministic.
+func clusterColumns(dist [][]float64) []merge {
+	n := len(dist)
+	if n == 0 {
+		return []merge{}
+	}
+	// d holds the distances between the current clusters, indexed by slot.
+	// Each merge reuses the slot of its left cluster.
+	d := make([][]float64, n)
+	for i := range d {
+		d[i] = append([]float64(nil), dist[i]...)
+	}
+	id := make([]int, n)
+	size := make([]int, n)
+	active := make([]bool, n)
+	for i := 0; i < n; i++ {
+		id[i] = i
+		size[i] = 1
+		active[i] = true
+	}
+	merges := make([]merge, 0, n-1)
+	for step := 0; step < n-1; step++ {
+		bi, bj := -1, -1
+		best := math.Inf(1)
+		for i := 0; i < n; i++ {
+			if !active[i] {
+				continue
+			}
+			for j := i + 1; j < n; j++ {
+				if active[j] && d[i][j] < best {
+					best = d[i][j]
+					bi, bj = i, j
+				}
+			}
+		}
+		merges = append(merges, merge{id[bi], id[bj], best, size[bi] + size[bj]})
+		// Lance-Williams update for average linkage
+		for k := 0; k < n; k++ {
+			if !active[k] || k == bi || k == bj {
+				continue
+			}
+			avg := (float64(size[bi])*d[bi][k] + float64(size[bj])*d[bj][k]) / float64(size[bi]+size[bj])
+			d[bi][k] = avg
+			d[k][bi] = avg
+		}
+		active[bj] = false
+		id[bi] = n + step
+		size[bi] += size[bj]
+	}
+	return merges
+}
+
+// cutTree assigns each of the n columns to a cluster by undoing merges until
+// there are k clusters or, when k is 0, until every remaining merge is at or
+// below the given height. Clusters are numbered from 1 in the order of
+// their first column.
+func cutTree(merges []merge, n, k int, height float64) []int {
+	parent := make([]int, n+len(merges))
+	for i := range parent {
+		parent[i] = i
+	}
+	for step, m := range merges {
+		if k > 0 && step >= n-k {
+			break
+		}
+		if k == 0 && m.height > height {
+			break
+		}
+		parent[m.left] = n + step
+		parent[m.right] = n + step
+	}
+	root := func(c int) int {
+		for parent[c] != c {
+			c = parent[c]
+		}
+		return c
+	}
+	labels := make([]int, n)
+	numbers := make(map[int]int)
+	for i := 0; i < n; i++ {
+		r := root(i)
+		if _, ok := numbers[r]; !ok {
+			numbers[r] = len(numbers) + 1
+		}
+		labels[i] = numbers[r]
+	}
+	return labels
+}
+
+// Clustering returns the merges from clustering the columns by
+// -cluster-distance, computing them the first time they are needed.
+func (t *Tally) Clustering() []merge {
+	if t.merges == nil {
+		t.merges = clusterColumns(columnDistances(t, args.ClusterDistance))
+	}
+	return t.merges
+}
+
+func validClusterDistance(kind string) bool {
+	for _, k := range clusterDistances {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// clusterName names cluster c for the merges output.
+func clusterName(c int, names []string) string {
+	if c < len(names) {
+		return names[c]
+	}
+	return fmt.Sprintf("#%d", c-len(names)+1)
+}
+
+// writeMerges prints the dendrogram of the column clustering as one line
+// per merge. The cluster formed by the k'th merge is referred to as #k.
+func writeMerges(w io.Writer, t *Tally, names []string) {
+	fmt.Fprintln(w, "merge\tleft\tright\theight\tsize")
+	for step, m := range t.Clustering() {
+		fmt.Fprintf(w, "#%d\t%s\t%s\t%s\t%d\n",
+			step+1, clusterName(m.left, names), clusterName(m.right, names), formatValue(m.height, 'f', 8), m.size)
+	}
+}
+
+// writeClusters prints the cluster each column is assigned to when the
+// dendrogram is cut at -cluster-k clusters or -cluster-height.
+func writeClusters(w io.Writer, t *Tally, names []string) {
+	labels := cutTree(t.Clustering(), len(names), args.ClusterK, args.ClusterHeight)
+	fmt.Fprintln(w, "column\tcluster")
+	for i, name := range names {
+		fmt.Fprintf(w, "%s\t%d\n", name, labels[i])
+	}
+}
+
+// leafOrder returns the columns in the order they appear as leaves of the
+// dendrogram, visiting the left cluster of each merge before the right.
+func leafOrder(merges []merge, n int) []int {
+	if n == 0 {
+		return nil
+	}
+	order := make([]int, 0, n)
+	stack := []int{n + len(merges) - 1}
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if c < n {
+			order = append(order, c)
+			continue
+		}
+		m := merges[c-n]
+		stack = append(stack, m.right, m.left)
+	}
+	return order
+}
+
+// writeReorder prints the permutation applied by -reorder: each column's
+// new position, name and position in the input.
+func writeReorder(w io.Writer, t *Tally, names []string) {
+	fmt.Fprintln(w, "position\tcolumn\toriginal")
+	for pos, orig := range t.Order {
+		fmt.Fprintf(w, "%d\t%s\t%d\n", pos+1, names[pos], orig+1)
+	}
+}
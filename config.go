@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// readConfig reads a YAML config file of options. Only a flat mapping is
+// supported: each line is 'option: value', where the option is the name of
+// one of the command line flags (and so one of the fields of Args). Values
+// may be quoted, and '#' starts a comment.
+func readConfig(path string) (map[string]string, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file '%s': %v", path, err)
+	}
+	defer fp.Close()
+	config := make(map[string]string)
+	scanner := bufio.NewScanner(fp)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := stripYAMLComment(scanner.Text())
+		if strings.TrimSpace(line) == "" || line == "---" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' || strings.HasPrefix(line, "- ") {
+			return nil, fmt.Errorf("line %d of '%s': only a flat mapping of options is supported", lineNum, path)
+		}
+		colon := strings.Index(line, ":")
+		if colon < 0 {
+			return nil, fmt.Errorf("line %d of '%s': expected 'option: value'", lineNum, path)
+		}
+		key := strings.TrimSpace(line[:colon])
+		value, err := unquoteYAML(strings.TrimSpace(line[colon+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d of '%s': %v", lineNum, path, err)
+		}
+		if _, ok := config[key]; ok {
+			return nil, fmt.Errorf("line %d of '%s': option '%s' is repeated", lineNum, path, key)
+		}
+		config[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// stripYAMLComment removes a trailing comment, leaving any '#' inside a
+// quoted value alone.
+func stripYAMLComment(line string) string {
+	var quote rune
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return strings.TrimRight(line[:i], " \t")
+		}
+	}
+	return strings.TrimRight(line, " \t")
+}
+
+func unquoteYAML(value string) (string, error) {
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+	}
+	if len(value) >= 1 && value[0] == '"' {
+		return strconv.Unquote(value)
+	}
+	return value, nil
+}
+
+// applyConfig sets the flags given in the config file, except for those
+// that were given on the command line, which take precedence.
+func applyConfig(config map[string]string) error {
+	onCommandLine := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		onCommandLine[f.Name] = true
+	})
+	for key, value := range config {
+		if key == "config" || flag.Lookup(key) == nil {
+			return fmt.Errorf("unknown option '%s' in config file", key)
+		}
+		if onCommandLine[key] {
+			continue
+		}
+		if err := flag.Set(key, value); err != nil {
+			return fmt.Errorf("invalid value '%s' for option '%s' in config file: %v", value, key, err)
+		}
+	}
+	return nil
+}
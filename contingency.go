@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// Contingency returns the 2x2 table of read counts for indicators i and j:
+// n11 reads have both, n10 have only i, n01 have only j and n00 have neither.
+func (t *Tally) Contingency(i, j int) (n11, n10, n01, n00 int) {
+	n11 = t.Joints[i][j]
+	n10 = t.Marginals[i] - n11
+	n01 = t.Marginals[j] - n11
+	n00 = t.NumReads - n11 - n10 - n01
+	return
+}
+
+// mcc computes the Matthews correlation coefficient of a 2x2 table. When
+// either indicator is constant the denominator is zero and the coefficient
+// is undefined, so NaN is returned.
+func mcc(n11, n10, n01, n00 int) float64 {
+	a, b, c, d := float64(n11), float64(n10), float64(n01), float64(n00)
+	denom := math.Sqrt((a + b) * (a + c) * (b + d) * (c + d))
+	if denom == 0 {
+		return math.NaN()
+	}
+	return (a*d - b*c) / denom
+}
+
+// phiBounds returns the largest and smallest phi coefficients attainable by
+// a 2x2 table with the same marginals. They are NaN when either indicator
+// is constant.
+func phiBounds(n11, n10, n01, n00 int) (max, min float64) {
+	n := float64(n11 + n10 + n01 + n00)
+	pa, pb := float64(n11+n10)/n, float64(n11+n01)/n
+	if pa == 0 || pa == 1 || pb == 0 || pb == 1 || math.IsNaN(pa) || math.IsNaN(pb) {
+		return math.NaN(), math.NaN()
+	}
+	lo, hi := math.Min(pa, pb), math.Max(pa, pb)
+	max = math.Sqrt(lo * (1 - hi) / (hi * (1 - lo)))
+	if pa+pb <= 1 {
+		min = -math.Sqrt(pa * pb / ((1 - pa) * (1 - pb)))
+	} else {
+		min = -math.Sqrt((1 - pa) * (1 - pb) / (pa * pb))
+	}
+	return max, min
+}
+
+// normalizedPhi divides the phi coefficient of a 2x2 table by the largest
+// one its marginals allow, phi_max, or by the magnitude of the smallest
+// when it is negative, so that pairs of different prevalences can be
+// compared on a scale from -1 to 1.
+func normalizedPhi(n11, n10, n01, n00 int) float64 {
+	phi := mcc(n11, n10, n01, n00)
+	max, min := phiBounds(n11, n10, n01, n00)
+	if phi < 0 {
+		return phi / -min
+	}
+	return phi / max
+}
+
+// writeMCC prints the Matthews correlation coefficient of every unordered
+// pair of indicators along with the cells of its contingency table, and
+// with -phi-max its phi/phi_max.
+func writeMCC(w io.Writer, t *Tally, names []string) {
+	for i, iName := range names {
+		for j := i + 1; j < len(names); j++ {
+			n11, n10, n01, n00 := t.Contingency(i, j)
+			fmt.Fprintf(w, "MCC( %s , %s ) = %s ; %d , %d , %d , %d",
+				iName, names[j], formatValue(mcc(n11, n10, n01, n00), 'f', 8), n11, n10, n01, n00)
+			if args.PhiMax {
+				fmt.Fprintf(w, " ; %s", formatValue(normalizedPhi(n11, n10, n01, n00), 'f', 8))
+			}
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// writeCorrelationMatrix prints the phi correlation of every pair of
+// indicators as a square matrix. A constant column's correlations are NaN,
+// even with itself. With -triangle the cells below the diagonal are left
+// empty.
+func writeCorrelationMatrix(w io.Writer, t *Tally, names []string) {
+	writeMatrix(w, names, func(i, j int) string {
+		if args.Triangle && j < i {
+			return ""
+		}
+		return formatValue(mcc(t.Contingency(i, j)), 'f', 8)
+	})
+}
+
+// writeContingencyLong prints the contingency table of every unordered pair
+// of indicators in long format, one row per cell, where cell 10 counts the
+// reads that are 1 in the first column and 0 in the second.
+func writeContingencyLong(w io.Writer, t *Tally, names []string) {
+	fmt.Fprintln(w, "column_a\tcolumn_b\tcell\tcount")
+	for i, iName := range names {
+		for j := i + 1; j < len(names); j++ {
+			n11, n10, n01, n00 := t.Contingency(i, j)
+			for _, cell := range []struct {
+				name  string
+				count int
+			}{{"11", n11}, {"10", n10}, {"01", n01}, {"00", n00}} {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", iName, names[j], cell.name, cell.count)
+			}
+		}
+	}
+}
+
+// yule computes Yule's Q and Yule's Y of a 2x2 table. A zero cell is fine
+// as long as one of the products ad and bc is non-zero; when both are zero
+// the coefficients are undefined and NaN is returned.
+func yule(n11, n10, n01, n00 int) (q, y float64) {
+	ad := float64(n11) * float64(n00)
+	bc := float64(n10) * float64(n01)
+	if ad+bc == 0 {
+		return math.NaN(), math.NaN()
+	}
+	q = (ad - bc) / (ad + bc)
+	y = (math.Sqrt(ad) - math.Sqrt(bc)) / (math.Sqrt(ad) + math.Sqrt(bc))
+	return q, y
+}
+
+// writeYule prints Yule's Q and Y of every unordered pair of indicators
+// along with the cells of its contingency table.
+func writeYule(w io.Writer, t *Tally, names []string) {
+	for i, iName := range names {
+		for j := i + 1; j < len(names); j++ {
+			n11, n10, n01, n00 := t.Contingency(i, j)
+			q, y := yule(n11, n10, n01, n00)
+			fmt.Fprintf(w, "Q( %s , %s ) = %s ; %d , %d , %d , %d\n", iName, names[j], formatValue(q, 'f', 8), n11, n10, n01, n00)
+			fmt.Fprintf(w, "Y( %s , %s ) = %s ; %d , %d , %d , %d\n", iName, names[j], formatValue(y, 'f', 8), n11, n10, n01, n00)
+		}
+	}
+}
+
+// chiSquare computes Pearson's chi-square statistic of a 2x2 table, and its
+// p-value on one degree of freedom. With yates it applies Yates' continuity
+// correction, taking 0.5 from each |observed - expected| before squaring,
+// though never below zero. When either indicator is constant a cell has no
+// reads expected, and both are NaN.
+func chiSquare(n11, n10, n01, n00 int, yates bool) (stat, p float64) {
+	n := float64(n11 + n10 + n01 + n00)
+	rows := [2]float64{float64(n11 + n10), float64(n01 + n00)}
+	cols := [2]float64{float64(n11 + n01), float64(n10 + n00)}
+	observed := [2][2]float64{{float64(n11), float64(n10)}, {float64(n01), float64(n00)}}
+	for r := range rows {
+		for c := range cols {
+			expected := rows[r] * cols[c] / n
+			if expected == 0 || math.IsNaN(expected) {
+				return math.NaN(), math.NaN()
+			}
+			diff := math.Abs(observed[r][c] - expected)
+			if yates {
+				diff = math.Max(diff-0.5, 0)
+			}
+			stat += diff * diff / expected
+		}
+	}
+	return stat, math.Erfc(math.Sqrt(stat / 2))
+}
+
+// writeChiSquare prints the chi-square test of independence of every
+// unordered pair of indicators, corrected with -yates, along with the cells
+// of its contingency table.
+func writeChiSquare(w io.Writer, t *Tally, names []string) {
+	for i, iName := range names {
+		for j := i + 1; j < len(names); j++ {
+			n11, n10, n01, n00 := t.Contingency(i, j)
+			stat, p := chiSquare(n11, n10, n01, n00, args.Yates)
+			fmt.Fprintf(w, "chi2( %s , %s ) = %s ; %s ; %d , %d , %d , %d\n", iName, names[j],
+				formatValue(stat, 'f', 8), formatValue(p, 'g', 8), n11, n10, n01, n00)
+		}
+	}
+}
+
+// lambda computes the Goodman-Kruskal lambda for predicting A from B, where
+// the table is given with A's value first. It is the proportional reduction
+// in the number of prediction errors made by guessing A's modal category
+// once B is known compared to not knowing B. When the modal category of A
+// already predicts perfectly there is nothing to reduce and lambda is 0.
+// The errors made without and with knowledge of B are also returned.
+func lambda(n11, n10, n01, n00 int) (l float64, without, with int) {
+	without = n11 + n10 + n01 + n00 - max(n11+n10, n01+n00)
+	with = (n11 + n01 - max(n11, n01)) + (n10 + n00 - max(n10, n00))
+	if without == 0 {
+		return 0, without, with
+	}
+	return float64(without-with) / float64(without), without, with
+}
+
+// writeLambda prints the Goodman-Kruskal lambda(A|B) for every ordered
+// pair of distinct indicators along with the prediction errors made
+// without and with knowledge of B.
+func writeLambda(w io.Writer, t *Tally, names []string) {
+	for i, iName := range names {
+		for j, jName := range names {
+			if i == j {
+				continue
+			}
+			l, without, with := lambda(t.Contingency(i, j))
+			fmt.Fprintf(w, "lambda( %s | %s ) = %s ; %d , %d\n", iName, jName, formatValue(l, 'f', 8), without, with)
+		}
+	}
+}
+
+// somersD computes Somers' D(A|B) of a 2x2 table given with A's value
+// first, treating A as the dependent variable. It is the excess of
+// concordant over discordant pairs of reads among the pairs that differ in
+// B, which for indicators is P(A|B) - P(A|not B). When B is constant no
+// pairs differ in B and NaN is returned.
+func somersD(n11, n10, n01, n00 int) float64 {
+	untied := float64(n11+n01) * float64(n10+n00)
+	if untied == 0 {
+		return math.NaN()
+	}
+	return (float64(n11)*float64(n00) - float64(n10)*float64(n01)) / untied
+}
+
+// writeSomersD prints Somers' D(A|B) for every ordered pair of distinct
+// indicators along with the cells of its contingency table.
+func writeSomersD(w io.Writer, t *Tally, names []string) {
+	for i, iName := range names {
+		for j, jName := range names {
+			if i == j {
+				continue
+			}
+			n11, n10, n01, n00 := t.Contingency(i, j)
+			fmt.Fprintf(w, "D( %s | %s ) = %s ; %d , %d , %d , %d\n",
+				iName, jName, formatValue(somersD(n11, n10, n01, n00), 'f', 8), n11, n10, n01, n00)
+		}
+	}
+}
+
+// jaccard computes the Jaccard similarity |A and B| / |A or B| of a 2x2
+// table, which is NaN when neither indicator ever occurs.
+func jaccard(n11, n10, n01, n00 int) float64 {
+	union := n11 + n10 + n01
+	if union == 0 {
+		return math.NaN()
+	}
+	return float64(n11) / float64(union)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// writeCPT prints the conditional probability table of the -target column
+// given the -parents columns: for every combination of parent values, the
+// number of reads with that combination, how many of them have the target
+// set, and the probability that the target is 1 smoothed by -smoothing.
+// Combinations are listed in binary counting order with the first parent
+// most significant.
+func writeCPT(w io.Writer, t *Tally, names []string) {
+	target, parents := t.Target, t.Parents
+	numCombos := 1 << uint(len(parents))
+	count := make([]int, numCombos)
+	targetCount := make([]int, numCombos)
+	for r := 0; r < t.NumReads; r++ {
+		combo := 0
+		for _, p := range parents {
+			combo <<= 1
+			if t.Columns[p].get(r) {
+				combo |= 1
+			}
+		}
+		count[combo]++
+		if t.Columns[target].get(r) {
+			targetCount[combo]++
+		}
+	}
+	for _, p := range parents {
+		fmt.Fprintf(w, "%s\t", names[p])
+	}
+	fmt.Fprintf(w, "count\t%s_count\tprobability\n", names[target])
+	for combo := 0; combo < numCombos; combo++ {
+		for k := range parents {
+			fmt.Fprintf(w, "%d\t", combo>>uint(len(parents)-1-k)&1)
+		}
+		fmt.Fprintf(w, "%d\t%d\t%s\n", count[combo], targetCount[combo], formatValue(smoothedProb(targetCount[combo], count[combo], 2), 'f', 8))
+	}
+}
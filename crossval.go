@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// foldMetrics are the evaluation metrics of the predictions for one fold.
+// Metrics that are undefined for a fold, such as the precision of a class
+// that was never predicted, are NaN.
+type foldMetrics struct {
+	accuracy  float64
+	precision [2]float64
+	recall    [2]float64
+	f1        [2]float64
+}
+
+// ratio returns a/b, or NaN when b is 0.
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return math.NaN()
+	}
+	return float64(a) / float64(b)
+}
+
+// f1Score returns the harmonic mean of precision and recall.
+func f1Score(precision, recall float64) float64 {
+	if precision+recall == 0 {
+		return 0
+	}
+	return 2 * precision * recall / (precision + recall)
+}
+
+// evaluate computes the metrics of predicted against actual classes.
+func evaluate(predicted, actual []int) foldMetrics {
+	var fm foldMetrics
+	var confusion [2][2]int // confusion[actual][predicted]
+	for r := range predicted {
+		confusion[actual[r]][predicted[r]]++
+	}
+	fm.accuracy = ratio(confusion[0][0]+confusion[1][1], len(predicted))
+	for c := 0; c < 2; c++ {
+		tp := confusion[c][c]
+		fm.precision[c] = ratio(tp, confusion[0][c]+confusion[1][c])
+		fm.recall[c] = ratio(tp, confusion[c][0]+confusion[c][1])
+		fm.f1[c] = f1Score(fm.precision[c], fm.recall[c])
+	}
+	return fm
+}
+
+// crossValidate evaluates naive Bayes by k-fold cross-validation. Reads are
+// assigned to folds by a shuffle drawn from the -seed random source, so the
+// folds are the same from run to run.
+func crossValidate(t *Tally, k int) []foldMetrics {
+	fold := make([]int, t.NumReads)
+	for pos, r := range random.Perm(t.NumReads) {
+		fold[r] = pos % k
+	}
+	label := t.Label
+	row := make([]int, len(t.FieldNames))
+	var metrics []foldMetrics
+	for f := 0; f < k; f++ {
+		var classCount [2]int
+		ones := [2][]int{make([]int, len(t.FieldNames)), make([]int, len(t.FieldNames))}
+		for r := 0; r < t.NumReads; r++ {
+			if fold[r] == f {
+				continue
+			}
+			t.readRow(r, row)
+			c := row[label]
+			classCount[c]++
+			for j, v := range row {
+				ones[c][j] += v
+			}
+		}
+		m := newNBModel(label, classCount, ones)
+		var predicted, actual []int
+		for r := 0; r < t.NumReads; r++ {
+			if fold[r] != f {
+				continue
+			}
+			t.readRow(r, row)
+			predicted = append(predicted, predictedClass(m.posterior(row)))
+			actual = append(actual, row[label])
+		}
+		metrics = append(metrics, evaluate(predicted, actual))
+	}
+	return metrics
+}
+
+// meanDefined returns the mean of the values that aren't NaN.
+func meanDefined(values []float64) float64 {
+	sum, n := 0.0, 0
+	for _, v := range values {
+		if !math.IsNaN(v) {
+			sum += v
+			n++
+		}
+	}
+	if n == 0 {
+		return math.NaN()
+	}
+	return sum / float64(n)
+}
+
+// writeCrossValidation prints the metrics of each of the -cv folds and their
+// means. Undefined metrics are left out of the means.
+func writeCrossValidation(w io.Writer, t *Tally, names []string) {
+	metrics := crossValidate(t, args.CV)
+	fmt.Fprintln(w, "fold\taccuracy\tprecision_1\trecall_1\tf1_1\tprecision_0\trecall_0\tf1_0")
+	cols := make([][]float64, 7)
+	for f, fm := range metrics {
+		values := []float64{fm.accuracy, fm.precision[1], fm.recall[1], fm.f1[1], fm.precision[0], fm.recall[0], fm.f1[0]}
+		fmt.Fprint(w, f+1)
+		for c, v := range values {
+			fmt.Fprintf(w, "\t%s", formatValue(v, 'f', 6))
+			cols[c] = append(cols[c], v)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprint(w, "mean")
+	for _, col := range cols {
+		fmt.Fprintf(w, "\t%s", formatValue(meanDefined(col), 'f', 6))
+	}
+	fmt.Fprintln(w)
+}
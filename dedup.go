@@ -0,0 +1,223 @@
+package main
+
+import "strconv"
+
+// dedupReader keeps only one occurrence of each read name from the reader
+// it wraps: the -dedup first or last, or with -dedup-agg all of them
+// combined into one. With -unique it is instead each distinct row, of the
+// same name and values, whose first occurrence is kept, and -multiplicity
+// keeps it along with the number of times it occurs. Keeping the first
+// needs only the set of names or rows seen so far, but the other policies
+// hold every read in memory until the end of the input, since a later line
+// may replace or add to any of them, or occur again.
+type dedupReader struct {
+	inner   rowReader
+	policy  string
+	seen    map[string]bool
+	rows    []bufferedRow
+	pos     int
+	row     bufferedRow
+	dropped int
+	done    bool
+}
+
+// A bufferedRow is a copy of one read's values, and the number of times
+// the row occurs for -multiplicity.
+type bufferedRow struct {
+	read   string
+	row    []int
+	weight float64
+	group  string
+	times  int
+}
+
+func newDedupReader(inner rowReader, policy string) *dedupReader {
+	return &dedupReader{inner: inner, policy: policy, seen: make(map[string]bool), pos: -1}
+}
+
+// current copies the values of the inner reader's current read.
+func (dr *dedupReader) current() bufferedRow {
+	return copyRow(dr.inner)
+}
+
+// copyRow copies the values of a reader's current read.
+func copyRow(reader rowReader) bufferedRow {
+	b := bufferedRow{read: reader.Read(), row: append([]int(nil), reader.Row()...), weight: 1, times: 1}
+	if weighted, ok := reader.(weightedReader); ok {
+		b.weight = weighted.Weight()
+	}
+	if grouped, ok := reader.(groupedReader); ok && args.GroupBy != "" {
+		b.group = grouped.Group()
+	}
+	return b
+}
+
+// key returns the whole row as a string, of its name followed by its
+// values, weight and group, so that equal rows have equal keys.
+func (b bufferedRow) key() string {
+	key := append([]byte(b.read), 0)
+	for _, v := range b.row {
+		key = strconv.AppendInt(key, int64(v), 10)
+		key = append(key, ',')
+	}
+	key = strconv.AppendFloat(append(key, 0), b.weight, 'g', -1, 64)
+	key = append(append(key, 0), b.group...)
+	return string(key)
+}
+
+func (dr *dedupReader) Fields() []string {
+	return dr.inner.Fields()
+}
+
+func (dr *dedupReader) Scan() bool {
+	if dr.done {
+		return false
+	}
+	if dr.policy != "first" && dr.policy != "unique" {
+		if dr.rows == nil && dr.policy == "last" {
+			dr.bufferLast()
+		} else if dr.rows == nil && dr.policy == "multiplicity" {
+			dr.bufferMultiplicity()
+		} else if dr.rows == nil {
+			dr.bufferAggregate()
+		}
+		dr.pos++
+		if dr.pos < len(dr.rows) {
+			dr.row = dr.rows[dr.pos]
+			return true
+		}
+	} else {
+		for dr.inner.Scan() {
+			b := dr.current()
+			key := b.read
+			if dr.policy == "unique" {
+				key = b.key()
+			}
+			if dr.seen[key] {
+				dr.dropped++
+				continue
+			}
+			dr.seen[key] = true
+			dr.row = b
+			return true
+		}
+	}
+	dr.done = true
+	if dr.dropped > 0 && dr.inner.Err() == nil && (dr.policy == "unique" || dr.policy == "multiplicity") {
+		infow(logFields{"collapsed": dr.dropped}, "collapsed %d duplicate rows\n", dr.dropped)
+	} else if dr.dropped > 0 && dr.inner.Err() == nil && args.DedupAgg != "" {
+		infow(logFields{"merged": dr.dropped}, "merged %d duplicate reads\n", dr.dropped)
+	} else if dr.dropped > 0 && dr.inner.Err() == nil {
+		infow(logFields{"dropped": dr.dropped}, "dropped %d duplicate reads\n", dr.dropped)
+	}
+	return false
+}
+
+// bufferLast reads the whole input, keeping the last occurrence of each
+// read in the position of that occurrence.
+func (dr *dedupReader) bufferLast() {
+	dr.rows = []bufferedRow{}
+	index := make(map[string]int)
+	for dr.inner.Scan() {
+		b := dr.current()
+		if k, ok := index[b.read]; ok {
+			dr.rows[k].row = nil
+			dr.dropped++
+		}
+		index[b.read] = len(dr.rows)
+		dr.rows = append(dr.rows, b)
+	}
+	kept := dr.rows[:0]
+	for _, b := range dr.rows {
+		if b.row != nil {
+			kept = append(kept, b)
+		}
+	}
+	dr.rows = kept
+}
+
+// bufferMultiplicity reads the whole input, keeping the first occurrence of
+// each distinct row in its position, counting how many times it occurs.
+func (dr *dedupReader) bufferMultiplicity() {
+	dr.rows = []bufferedRow{}
+	index := make(map[string]int)
+	for dr.inner.Scan() {
+		b := dr.current()
+		key := b.key()
+		if k, ok := index[key]; ok {
+			dr.rows[k].times++
+			dr.dropped++
+			continue
+		}
+		index[key] = len(dr.rows)
+		dr.rows = append(dr.rows, b)
+	}
+}
+
+// bufferAggregate reads the whole input, combining the values of every
+// occurrence of each read in the position of its first occurrence by the
+// -dedup-agg policy: a value is 1 if it is 1 in any of them with or, in all
+// of them with and, or in at least -dedup-min of them with sum. The weight
+// and group are the first occurrence's.
+func (dr *dedupReader) bufferAggregate() {
+	dr.rows = []bufferedRow{}
+	index := make(map[string]int)
+	var occurrences []int
+	for dr.inner.Scan() {
+		read := dr.inner.Read()
+		k, ok := index[read]
+		if !ok {
+			index[read] = len(dr.rows)
+			dr.rows = append(dr.rows, dr.current())
+			occurrences = append(occurrences, 1)
+			continue
+		}
+		// Sum the ones of every occurrence, to be decided at the end
+		for i, v := range dr.inner.Row() {
+			dr.rows[k].row[i] += v
+		}
+		occurrences[k]++
+		dr.dropped++
+	}
+	for k, b := range dr.rows {
+		for i, ones := range b.row {
+			var v bool
+			switch dr.policy {
+			case "or":
+				v = ones > 0
+			case "and":
+				v = ones == occurrences[k]
+			case "sum":
+				v = ones >= args.DedupMin
+			}
+			b.row[i] = 0
+			if v {
+				b.row[i] = 1
+			}
+		}
+	}
+}
+
+func (dr *dedupReader) Read() string {
+	return dr.row.read
+}
+
+func (dr *dedupReader) Row() []int {
+	return dr.row.row
+}
+
+func (dr *dedupReader) Weight() float64 {
+	return dr.row.weight
+}
+
+func (dr *dedupReader) Group() string {
+	return dr.row.group
+}
+
+func (dr *dedupReader) Multiplicity() int {
+	return dr.row.times
+}
+
+func (dr *dedupReader) Err() error {
+	return dr.inner.Err()
+}
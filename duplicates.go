@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Hamming returns the number of reads on which indicators i and j differ.
+func (t *Tally) Hamming(i, j int) int {
+	return t.Marginals[i] + t.Marginals[j] - 2*t.Joints[i][j]
+}
+
+// writeHamming prints the Hamming distance between every pair of
+// indicators, or with -hamming-normalize the fraction of reads on which
+// they differ.
+func writeHamming(w io.Writer, t *Tally, names []string) {
+	for i, iName := range names {
+		for j := i + 1; j < len(names); j++ {
+			d := t.Hamming(i, j)
+			if args.HammingNormalize {
+				fmt.Fprintf(w, "hamming( %s , %s ) = %s ; %d\n", iName, names[j], formatValue(float64(d)/float64(t.NumReads), 'f', 8), d)
+			} else {
+				fmt.Fprintf(w, "hamming( %s , %s ) = %d\n", iName, names[j], d)
+			}
+		}
+	}
+}
+
+// writeNearDuplicates prints the pairs of columns that agree on at least
+// -near-agreement of the reads, along with the number of reads on which they
+// differ.
+func writeNearDuplicates(w io.Writer, t *Tally, names []string) {
+	for i, iName := range names {
+		for j := i + 1; j < len(names); j++ {
+			differ := xorCount(t.Columns[i], t.Columns[j])
+			agreement := 1 - float64(differ)/float64(t.NumReads)
+			if agreement >= args.NearAgreement {
+				fmt.Fprintf(w, "agree( %s , %s ) = %s ; %d\n", iName, names[j], formatValue(agreement, 'f', 8), differ)
+			}
+		}
+	}
+}
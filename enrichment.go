@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// logChoose returns the natural log of the binomial coefficient n choose k,
+// which stays finite where the coefficient itself would overflow.
+func logChoose(n, k int) float64 {
+	a, _ := math.Lgamma(float64(n + 1))
+	b, _ := math.Lgamma(float64(k + 1))
+	c, _ := math.Lgamma(float64(n - k + 1))
+	return a - b - c
+}
+
+// hypergeometricUpperTail returns the probability that drawing n of N reads,
+// K of which are 1 in one column, finds at least k that are 1: the
+// one-sided p-value of an overlap of k or more. The terms are summed
+// relative to the largest, in logs, so that tiny p-values don't underflow
+// to zero early.
+func hypergeometricUpperTail(k, K, n, N int) float64 {
+	lo, hi := max(k, n-(N-K), 0), min(K, n)
+	if lo > hi {
+		return 0
+	}
+	logTotal := logChoose(N, n)
+	logTerm := func(x int) float64 {
+		return logChoose(K, x) + logChoose(N-K, n-x) - logTotal
+	}
+	// The terms fall away from the mode, so the largest in the tail is the
+	// mode itself when it lies in the tail, or else the first
+	mode := (n + 1) * (K + 1) / (N + 2)
+	peak := logTerm(max(lo, min(mode, hi)))
+	sum := 0.0
+	for x := lo; x <= hi; x++ {
+		sum += math.Exp(logTerm(x) - peak)
+	}
+	return math.Min(1, math.Exp(peak+math.Log(sum)))
+}
+
+// An enrichedPair is the -enrichment test of one pair of indicators.
+type enrichedPair struct {
+	i, j     int
+	observed int
+	expected float64
+	p        float64
+	adjusted float64
+}
+
+// enrichedPairs tests the overlap of every unordered pair of indicators,
+// adjusting the p-values by the -correction over them all. With
+// -top-enriched only that many of the most significant are returned, in
+// increasing order of p-value.
+func enrichedPairs(t *Tally) []enrichedPair {
+	var pairs []enrichedPair
+	var pValues []float64
+	for i := range t.Marginals {
+		for j := i + 1; j < len(t.Marginals); j++ {
+			observed := t.Joints[i][j]
+			p := hypergeometricUpperTail(observed, t.Marginals[i], t.Marginals[j], t.NumReads)
+			pairs = append(pairs, enrichedPair{
+				i:        i,
+				j:        j,
+				observed: observed,
+				expected: float64(t.Marginals[i]) * float64(t.Marginals[j]) / float64(t.NumReads),
+				p:        p,
+			})
+			pValues = append(pValues, p)
+		}
+	}
+	for k, q := range adjustPValues(pValues, args.Correction) {
+		pairs[k].adjusted = q
+	}
+	if args.TopEnriched > 0 {
+		sort.SliceStable(pairs, func(a, b int) bool {
+			return pairs[a].p < pairs[b].p
+		})
+		if len(pairs) > args.TopEnriched {
+			pairs = pairs[:args.TopEnriched]
+		}
+	}
+	return pairs
+}
+
+// writeEnrichment prints the hypergeometric p-value of the overlap of every
+// unordered pair of indicators being at least as large as observed, given
+// their marginals, along with the observed and the expected overlap, and
+// the p-value adjusted by any -correction.
+func writeEnrichment(w io.Writer, t *Tally, names []string) {
+	for _, pair := range enrichedPairs(t) {
+		fmt.Fprintf(w, "enrichment( %s , %s ) = %s ; %d , %s",
+			names[pair.i], names[pair.j], formatValue(pair.p, 'g', 8), pair.observed, formatValue(pair.expected, 'f', 4))
+		if args.Correction != "none" {
+			fmt.Fprintf(w, " ; %s", formatValue(pair.adjusted, 'g', 8))
+		}
+		fmt.Fprintln(w)
+	}
+}
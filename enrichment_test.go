@@ -0,0 +1,36 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// TestHypergeometricUpperTail checks the tail, which is R's
+// phyper(k - 1, K, N - K, n, lower.tail = FALSE), against its exact values
+// from summing the binomial coefficients as rationals, out to p-values far
+// smaller than summing the terms as floats could reach.
+func TestHypergeometricUpperTail(t *testing.T) {
+	for _, c := range []struct {
+		k, K, n, N int
+		want       float64
+	}{
+		{0, 10, 5, 50, 1},
+		{1, 10, 5, 50, 0.6894372179954313},
+		{3, 10, 5, 50, 0.0482603031962091},
+		{5, 10, 5, 50, 0.00011893749174045196},
+		{6, 10, 5, 50, 0},
+		{2, 5, 3, 10, 0.5},
+		{10, 100, 200, 1000, 0.9984736972391677},
+		{20, 100, 200, 1000, 0.5440760001458428},
+		{60, 100, 200, 1000, 7.572690410181317e-21},
+		{80, 1000, 1000, 20000, 2.0967264676297844e-05},
+		{250, 500, 500, 2000, 2.328758988612664e-46},
+		{50, 100, 100, 10000, 2.7325767758389867e-78},
+		{100, 100, 100, 10000, 1.5335431295520405e-242},
+	} {
+		got := hypergeometricUpperTail(c.k, c.K, c.n, c.N)
+		if math.Abs(got-c.want) > 1e-9*c.want {
+			t.Errorf("hypergeometricUpperTail(%d, %d, %d, %d) = %g, want %g", c.k, c.K, c.n, c.N, got, c.want)
+		}
+	}
+}
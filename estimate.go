@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// estimate reads the header and the first -estimate-reads reads of the
+// input, and prints what a full run would cost as tab-separated 'name,
+// value' lines instead of computing any outputs: the memory the joint
+// counts take, the number of lines in each of the main outputs, and, from
+// the rate at which the sampled reads were parsed and tallied, a rough time
+// for the whole input. The number of reads in an -input file is projected
+// from its size and the bytes read for the sample, so it is only rough; on
+// stdin it is unknown, unless the sample reached the end.
+func estimate() {
+	fp, err := openInputFile()
+	if err != nil {
+		fatal(err)
+	}
+	defer fp.Close()
+	counter := &countingReader{r: fp}
+	reader, err := openReader(counter)
+	if err != nil {
+		fatal("invalid input:", err)
+	}
+	numFields := len(reader.Fields())
+	tally := NewTally(reader.Fields(), true)
+	start := time.Now()
+	numReads := 0
+	ended := false
+	for numReads < args.EstimateReads {
+		if !reader.Scan() {
+			ended = true
+			break
+		}
+		tally.Add("", reader.Row())
+		numReads++
+	}
+	if err := reader.Err(); err != nil {
+		fatal("invalid input:", err)
+	}
+	seconds := time.Since(start).Seconds()
+
+	// The pairs written unless -skip-zero-joints or -minsupport leave out
+	// some of them
+	numPairs := int64(numFields) * int64(numFields)
+	numOrdered := numPairs
+	if args.Triangle {
+		numPairs = int64(numFields) * int64(numFields+1) / 2
+	}
+	if !args.Self {
+		numPairs -= int64(numFields)
+		numOrdered -= int64(numFields)
+	}
+
+	w := os.Stdout
+	fmt.Fprintf(w, "columns\t%d\n", numFields)
+	fmt.Fprintf(w, "joints_memory_bytes\t%d\n", int64(numFields)*int64(numFields)*strconv.IntSize/8)
+	fmt.Fprintf(w, "marginals_lines\t%d\n", numFields)
+	fmt.Fprintf(w, "joints_lines\t%d\n", numPairs)
+	fmt.Fprintf(w, "conditionals_lines\t%d\n", numOrdered)
+	fmt.Fprintf(w, "sampled_reads\t%d\n", numReads)
+	if numReads == 0 {
+		return
+	}
+	perRead := seconds / float64(numReads)
+	fmt.Fprintf(w, "seconds_per_read\t%0.9f\n", perRead)
+	totalReads := int64(-1)
+	if ended {
+		totalReads = int64(numReads)
+	} else if args.Input != "" {
+		if fi, err := os.Stat(args.Input); err == nil && counter.n > 0 {
+			totalReads = int64(float64(fi.Size()) / float64(counter.n) * float64(numReads))
+		}
+	}
+	if totalReads < 0 {
+		return
+	}
+	fmt.Fprintf(w, "projected_reads\t%d\n", totalReads)
+	fmt.Fprintf(w, "projected_seconds\t%0.3f\n", perRead*float64(totalReads))
+}
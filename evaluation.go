@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// labeledPredictions returns the predictions whose actual class is known.
+func labeledPredictions(t *Tally) []prediction {
+	var labeled []prediction
+	for _, p := range t.Predictions() {
+		if p.actual >= 0 {
+			labeled = append(labeled, p)
+		}
+	}
+	return labeled
+}
+
+// writeConfusion prints the counts of true and false positives and
+// negatives of the predictions at the -decision-threshold, followed by the
+// metrics derived from them. Predictions of reads with no actual label are
+// left out.
+func writeConfusion(w io.Writer, t *Tally, names []string) {
+	var tp, fp, fn, tn int
+	for _, p := range labeledPredictions(t) {
+		switch predicted := predictedClass(p.score); {
+		case predicted == 1 && p.actual == 1:
+			tp++
+		case predicted == 1:
+			fp++
+		case p.actual == 1:
+			fn++
+		default:
+			tn++
+		}
+	}
+	precision := ratio(tp, tp+fp)
+	recall := ratio(tp, tp+fn)
+	fmt.Fprintf(w, "TP\t%d\n", tp)
+	fmt.Fprintf(w, "FP\t%d\n", fp)
+	fmt.Fprintf(w, "FN\t%d\n", fn)
+	fmt.Fprintf(w, "TN\t%d\n", tn)
+	fmt.Fprintf(w, "accuracy\t%s\n", formatValue(ratio(tp+tn, tp+fp+fn+tn), 'f', 6))
+	fmt.Fprintf(w, "precision\t%s\n", formatValue(precision, 'f', 6))
+	fmt.Fprintf(w, "recall\t%s\n", formatValue(recall, 'f', 6))
+	fmt.Fprintf(w, "f1\t%s\n", formatValue(f1Score(precision, recall), 'f', 6))
+}
+
+// A sweepPoint gives the true and false positives counted when every read
+// scoring at least threshold is predicted to be class 1.
+type sweepPoint struct {
+	threshold float64
+	tp, fp    int
+}
+
+// thresholdSweep returns the points of a sweep of the decision threshold
+// down through each distinct score of the labeled predictions, along with
+// the numbers of actual positives and negatives.
+func thresholdSweep(t *Tally) (points []sweepPoint, pos, neg int) {
+	preds := labeledPredictions(t)
+	sort.SliceStable(preds, func(a, b int) bool {
+		return preds[a].score > preds[b].score
+	})
+	var tp, fp int
+	for k, p := range preds {
+		if p.actual == 1 {
+			tp++
+		} else {
+			fp++
+		}
+		if k+1 == len(preds) || preds[k+1].score != p.score {
+			points = append(points, sweepPoint{p.score, tp, fp})
+		}
+	}
+	return points, tp, fp
+}
+
+// writeROC prints the false and true positive rates of the predictions at
+// each threshold, from the point where nothing is predicted positive to the
+// one where everything is.
+func writeROC(w io.Writer, t *Tally, names []string) {
+	points, pos, neg := thresholdSweep(t)
+	fmt.Fprintln(w, "threshold\tfpr\ttpr")
+	fmt.Fprintf(w, "%s\t%s\t%s\n", formatValue(math.Inf(1), 'f', 8), formatValue(0.0, 'f', 8), formatValue(0.0, 'f', 8))
+	for _, pt := range points {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", formatValue(pt.threshold, 'f', 8), formatValue(ratio(pt.fp, neg), 'f', 8), formatValue(ratio(pt.tp, pos), 'f', 8))
+	}
+}
+
+// auc returns the area under the ROC curve of the labeled predictions by the
+// Mann-Whitney estimator: the probability that a random positive scores
+// above a random negative, counting ties as one half. It is NaN if either
+// class is absent.
+func auc(t *Tally) (area float64, pos, neg int) {
+	preds := labeledPredictions(t)
+	sort.SliceStable(preds, func(a, b int) bool {
+		return preds[a].score < preds[b].score
+	})
+	rankSum := 0.0
+	for k := 0; k < len(preds); {
+		// tied scores share the average of their ranks
+		end := k
+		for end < len(preds) && preds[end].score == preds[k].score {
+			end++
+		}
+		rank := float64(k+1+end) / 2
+		for ; k < end; k++ {
+			if preds[k].actual == 1 {
+				rankSum += rank
+				pos++
+			} else {
+				neg++
+			}
+		}
+	}
+	if pos == 0 || neg == 0 {
+		return math.NaN(), pos, neg
+	}
+	u := rankSum - float64(pos)*float64(pos+1)/2
+	return u / (float64(pos) * float64(neg)), pos, neg
+}
+
+// writeAUC prints the area under the ROC curve of the predictions, along
+// with the numbers of positive and negative reads.
+func writeAUC(w io.Writer, t *Tally, names []string) {
+	area, pos, neg := auc(t)
+	fmt.Fprintf(w, "AUC( %s ) = %s ; %d , %d\n", names[t.Label], formatValue(area, 'f', 8), pos, neg)
+}
+
+// writePrecisionRecall prints the precision and recall of the predictions at
+// each threshold, followed by their average precision: the sum of the
+// precision at each threshold weighted by the gain in recall there.
+func writePrecisionRecall(w io.Writer, t *Tally, names []string) {
+	points, pos, _ := thresholdSweep(t)
+	fmt.Fprintln(w, "threshold\tprecision\trecall")
+	ap, prevRecall := 0.0, 0.0
+	for _, pt := range points {
+		precision := ratio(pt.tp, pt.tp+pt.fp)
+		recall := ratio(pt.tp, pos)
+		fmt.Fprintf(w, "%s\t%s\t%s\n", formatValue(pt.threshold, 'f', 8), formatValue(precision, 'f', 8), formatValue(recall, 'f', 8))
+		ap += (recall - prevRecall) * precision
+		prevRecall = recall
+	}
+	fmt.Fprintf(w, "AP( %s ) = %s ; %d\n", names[t.Label], formatValue(ap, 'f', 8), pos)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// exampleMatrix is what -example writes: a small wide format matrix showing
+// the input the tool expects. The first line is a header naming the read
+// column and then each indicator column, and each line after it gives a
+// read's name and then a 0 or 1 for each column, all separated by tabs.
+const exampleMatrix = `read	geneA	geneB	geneC	geneD
+read1	1	1	0	0
+read2	1	1	1	0
+read3	0	1	1	0
+read4	1	0	0	1
+read5	0	0	1	1
+read6	1	1	0	0
+`
+
+// writeExample writes the -example matrix to stdout instead of computing
+// any outputs.
+func writeExample() {
+	if _, err := fmt.Fprint(os.Stdout, exampleMatrix); err != nil {
+		fatal("failed to write the example matrix:", err)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// A correlation makes one generated column follow another: in each read,
+// with probability strength the target is set to the source, or to its
+// complement if strength is negative, and otherwise drawn independently.
+// Where both columns have the -generate-density and strength is positive,
+// the phi coefficient between them is strength; a negative strength gives
+// a phi of exactly strength only at a density of 0.5, since the copied
+// complements have the opposite density.
+type correlation struct {
+	source, target int
+	strength       float64
+}
+
+// parseCorrelations parses the -generate-correlate list of
+// 'source:target=strength' entries, naming columns c0, c1, ... as
+// generated.
+func parseCorrelations(spec string, numFields int) ([]correlation, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	var links []correlation
+	column := func(name string) (int, error) {
+		j, err := strconv.Atoi(strings.TrimPrefix(name, "c"))
+		if !strings.HasPrefix(name, "c") || err != nil || j < 0 || j >= numFields {
+			return 0, fmt.Errorf("no generated column '%s'; they are c0 to c%d", name, numFields-1)
+		}
+		return j, nil
+	}
+	for _, entry := range strings.Split(spec, ",") {
+		pair, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		source, target, ok2 := strings.Cut(pair, ":")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("expected 'source:target=strength' in '%s'", entry)
+		}
+		var link correlation
+		var err error
+		if link.source, err = column(source); err != nil {
+			return nil, err
+		}
+		if link.target, err = column(target); err != nil {
+			return nil, err
+		}
+		if link.source == link.target {
+			return nil, fmt.Errorf("column '%s' can't be correlated with itself", source)
+		}
+		link.strength, err = strconv.ParseFloat(value, 64)
+		if err != nil || link.strength < -1 || link.strength > 1 {
+			return nil, fmt.Errorf("strength '%s' of '%s' must be a number from -1 to 1", value, pair)
+		}
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+// generateMatrix writes a wide format matrix of random indicators with the
+// given number of reads and columns, each 1 with probability density, and
+// then made to follow one another by the correlations in the order given.
+// It draws from the -seed random source, so the same seed always gives
+// the same matrix.
+func generateMatrix(w io.Writer, numReads, numFields int, density float64, links []correlation) {
+	fmt.Fprint(w, "read")
+	for j := 0; j < numFields; j++ {
+		fmt.Fprintf(w, "\tc%d", j)
+	}
+	fmt.Fprintln(w)
+	row := make([]byte, numFields)
+	for i := 0; i < numReads; i++ {
+		for j := range row {
+			row[j] = draw(density)
+		}
+		for _, link := range links {
+			if random.Float64() >= math.Abs(link.strength) {
+				continue
+			}
+			row[link.target] = row[link.source]
+			if link.strength < 0 {
+				row[link.target] = 1 - row[link.source]
+			}
+		}
+		fmt.Fprintf(w, "r%d", i)
+		for _, v := range row {
+			fmt.Fprintf(w, "\t%d", v)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// draw returns 1 with probability p and 0 otherwise.
+func draw(p float64) byte {
+	if random.Float64() < p {
+		return 1
+	}
+	return 0
+}
+
+// generate writes the -generate matrix to stdout instead of computing any
+// outputs.
+func generate() {
+	links, err := parseCorrelations(args.GenerateCorrelate, args.GenerateColumns)
+	if err != nil {
+		fatal("-generate-correlate:", err)
+	}
+	w := bufio.NewWriter(os.Stdout)
+	generateMatrix(w, args.GenerateReads, args.GenerateColumns, args.GenerateDensity, links)
+	if err := w.Flush(); err != nil {
+		fatal("failed to write the generated matrix:", err)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files with the current outputs")
+
+// resetArgs sets every option back to its default, so each run starts from
+// the same state as a fresh process would.
+func resetArgs(t *testing.T) {
+	t.Helper()
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		// The test binary's own flags are left alone
+		if err == nil && !strings.HasPrefix(f.Name, "test.") && f.Name != "update" {
+			err = f.Value.Set(f.DefValue)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	badRows = badRowLog{}
+	defaultThreshold = math.NaN()
+	columnThresholds = nil
+}
+
+// runOutputs runs the input through the same steps as main, with the given
+// options, writing the marginals, joints and conditionals to files in a
+// temporary directory, and returns the contents of each file by output.
+func runOutputs(t *testing.T, input string, options ...string) map[string][]byte {
+	t.Helper()
+	resetArgs(t)
+	dir := t.TempDir()
+	argv := append([]string{
+		"-q",
+		"-input", input,
+		"-marginals", filepath.Join(dir, "marginals"),
+		"-joints", filepath.Join(dir, "joints"),
+		"-conditionals", filepath.Join(dir, "conditionals"),
+	}, options...)
+	if err := flag.CommandLine.Parse(argv); err != nil {
+		t.Fatal(err)
+	}
+	random = rand.New(rand.NewSource(args.Seed))
+	req, err := openOutputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tally, err := tallyInput(context.Background(), req, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeOutputs(context.Background(), tally, tally.FieldNames); err != nil {
+		t.Fatal(err)
+	}
+	written := make(map[string][]byte)
+	for _, out := range selectedOutputs() {
+		if err := out.fp.Close(); err != nil {
+			t.Fatal(err)
+		}
+		name := filepath.Base(*out.path)
+		if written[name], err = os.ReadFile(*out.path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return written
+}
+
+// TestGolden compares the outputs for small fixed inputs against the files
+// checked in under testdata/golden, byte for byte. Run with -update to
+// rewrite them after an intended change to the output.
+func TestGolden(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		options []string
+	}{
+		{"basic", "basic.tsv", nil},
+		{"basic-triangle", "basic.tsv", []string{"-triangle", "-self=false"}},
+		{"basic-precision", "basic.tsv", []string{"-precision", "3"}},
+		// A column of all 1s, and one of all 0s whose conditionals have
+		// no reads to divide by
+		{"constant", "constant.tsv", nil},
+		// A header but no reads, so nothing has anything to divide by
+		{"empty", "empty.tsv", nil},
+		// The matrix written by -example
+		{"example", "", nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			input := filepath.Join("testdata", "golden", c.input)
+			if c.input == "" {
+				input = filepath.Join(t.TempDir(), "example.tsv")
+				if err := os.WriteFile(input, []byte(exampleMatrix), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			written := runOutputs(t, input, c.options...)
+			for _, output := range []string{"marginals", "joints", "conditionals"} {
+				golden := filepath.Join("testdata", "golden", c.name+"."+output)
+				if *update {
+					if err := os.WriteFile(golden, written[output], 0644); err != nil {
+						t.Fatal(err)
+					}
+					continue
+				}
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(written[output], want) {
+					t.Errorf("%s differs from %s:\n%s\nwant:\n%s", output, golden, written[output], want)
+				}
+			}
+		})
+	}
+}
+
+// TestParallelOutput checks that reading with -threads gives the same
+// outputs on every run, and the same as reading with a single thread, over
+// an input of many batches of lines, however the batches are scheduled.
+func TestParallelOutput(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "input.tsv")
+	random = rand.New(rand.NewSource(1))
+	var buf bytes.Buffer
+	generateMatrix(&buf, 10*parallelBatchSize+7, 12, 0.3, []correlation{{0, 1, 0.8}})
+	if err := os.WriteFile(input, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := runOutputs(t, input)
+	for run := 0; run < 5; run++ {
+		written := runOutputs(t, input, "-threads", "4")
+		for _, output := range []string{"marginals", "joints", "conditionals"} {
+			if !bytes.Equal(written[output], want[output]) {
+				t.Fatalf("run %d with -threads 4 wrote different %s from a single thread", run, output)
+			}
+		}
+	}
+}
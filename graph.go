@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// The statistics that pairs of indicators can be linked by in the graph
+// outputs. Conditional edges are directed from the condition B to A with the
+// weight P(A|B); the other statistics are symmetric and give undirected
+// edges.
+var edgeStats = []string{"correlation", "lift", "conditional"}
+
+// An edge links two indicators whose statistic exceeds the threshold.
+type edge struct {
+	from   int
+	to     int
+	weight float64
+}
+
+// lift computes P(A,B) / (P(A)P(B)) from a 2x2 table, which is NaN when
+// either indicator never occurs.
+func lift(n11, n10, n01, n00 int) float64 {
+	n := float64(n11 + n10 + n01 + n00)
+	expected := float64(n11+n10) * float64(n11+n01) / n
+	if expected == 0 {
+		return math.NaN()
+	}
+	return float64(n11) / expected
+}
+
+func validEdgeStat(stat string) bool {
+	for _, s := range edgeStats {
+		if s == stat {
+			return true
+		}
+	}
+	return false
+}
+
+func directedEdgeStat(stat string) bool {
+	return stat == "conditional"
+}
+
+// graphEdges returns the edges whose statistic is strictly greater than the
+// threshold. Undefined (NaN) statistics never make an edge. Self pairs are
+// not considered.
+func graphEdges(t *Tally, stat string, threshold float64) []edge {
+	var edges []edge
+	for i := range t.FieldNames {
+		for j := range t.FieldNames {
+			if i == j || (!directedEdgeStat(stat) && j < i) {
+				continue
+			}
+			var weight float64
+			switch stat {
+			case "correlation":
+				weight = mcc(t.Contingency(i, j))
+			case "lift":
+				weight = lift(t.Contingency(i, j))
+			case "conditional":
+				if t.Marginals[i] == 0 {
+					continue
+				}
+				weight = float64(t.Joints[i][j]) / float64(t.Marginals[i])
+			}
+			if weight > threshold {
+				edges = append(edges, edge{i, j, weight})
+			}
+		}
+	}
+	return edges
+}
+
+// dotQuote quotes a column name as a DOT identifier.
+func dotQuote(name string) string {
+	name = strings.ReplaceAll(name, `\`, `\\`)
+	name = strings.ReplaceAll(name, `"`, `\"`)
+	return `"` + name + `"`
+}
+
+// writeDot prints a Graphviz graph with a node for every indicator and an
+// edge for every pair whose -edge-stat exceeds -edge-threshold.
+func writeDot(w io.Writer, t *Tally, names []string) {
+	writeDotGraph(w, names, graphEdges(t, args.EdgeStat, args.EdgeThreshold), directedEdgeStat(args.EdgeStat))
+}
+
+// writeDotGraph prints a Graphviz graph with a node for every indicator and
+// the given edges. The statistic of each edge is its label and a custom stat
+// attribute, rather than its weight, which dot needs to be a non-negative
+// integer.
+func writeDotGraph(w io.Writer, names []string, edges []edge, directed bool) {
+	graph, link := "graph", "--"
+	if directed {
+		graph, link = "digraph", "->"
+	}
+	fmt.Fprintf(w, "%s matrixprobs {\n", graph)
+	for _, name := range names {
+		fmt.Fprintf(w, "\t%s;\n", dotQuote(name))
+	}
+	for _, e := range edges {
+		fmt.Fprintf(w, "\t%s %s %s [stat=%s, label=\"%s\"];\n",
+			dotQuote(names[e.from]), link, dotQuote(names[e.to]), formatValue(e.weight, 'f', 8), formatValue(e.weight, 'f', 4))
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// writeEdgeList prints a tab-separated edge list with a header row for
+// every pair whose -edge-stat exceeds -edge-threshold, marking whether each
+// edge is directed.
+func writeEdgeList(w io.Writer, t *Tally, names []string) {
+	writeEdges(w, names, graphEdges(t, args.EdgeStat, args.EdgeThreshold), directedEdgeStat(args.EdgeStat))
+}
+
+// writeEdges prints the given edges as a tab-separated edge list with a
+// header row.
+func writeEdges(w io.Writer, names []string, edges []edge, directed bool) {
+	fmt.Fprintln(w, "source\ttarget\tweight\tdirected")
+	for _, e := range edges {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\n", names[e.from], names[e.to], formatValue(e.weight, 'f', 8), directed)
+	}
+}
+
+// xmlEscape escapes a string for use in XML text or attribute values.
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// writeGraphML prints a GraphML document with a node for every indicator
+// and an edge for every pair whose -edge-stat exceeds -edge-threshold. Nodes
+// carry the column name, marginal probability and degree; edges carry the
+// statistic.
+func writeGraphML(w io.Writer, t *Tally, names []string) {
+	edges := graphEdges(t, args.EdgeStat, args.EdgeThreshold)
+	degree := make([]int, len(names))
+	for _, e := range edges {
+		degree[e.from]++
+		degree[e.to]++
+	}
+	edgeDefault := "undirected"
+	if directedEdgeStat(args.EdgeStat) {
+		edgeDefault = "directed"
+	}
+	fmt.Fprintln(w, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(w, `<graphml xmlns="http://graphml.graphdrawing.org/xmlns">`)
+	fmt.Fprintln(w, `  <key id="name" for="node" attr.name="name" attr.type="string"/>`)
+	fmt.Fprintln(w, `  <key id="marginal" for="node" attr.name="marginal" attr.type="double"/>`)
+	fmt.Fprintln(w, `  <key id="degree" for="node" attr.name="degree" attr.type="int"/>`)
+	fmt.Fprintf(w, "  <key id=\"%s\" for=\"edge\" attr.name=\"%s\" attr.type=\"double\"/>\n", args.EdgeStat, args.EdgeStat)
+	fmt.Fprintf(w, "  <graph id=\"matrixprobs\" edgedefault=\"%s\">\n", edgeDefault)
+	for i, name := range names {
+		mar := float64(t.Marginals[i]) / float64(t.NumReads)
+		fmt.Fprintf(w, "    <node id=\"n%d\">\n", i)
+		fmt.Fprintf(w, "      <data key=\"name\">%s</data>\n", xmlEscape(name))
+		fmt.Fprintf(w, "      <data key=\"marginal\">%s</data>\n", formatValue(mar, 'f', 8))
+		fmt.Fprintf(w, "      <data key=\"degree\">%d</data>\n", degree[i])
+		fmt.Fprintln(w, "    </node>")
+	}
+	for _, e := range edges {
+		fmt.Fprintf(w, "    <edge source=\"n%d\" target=\"n%d\">\n", e.from, e.to)
+		fmt.Fprintf(w, "      <data key=\"%s\">%s</data>\n", args.EdgeStat, formatValue(e.weight, 'f', 8))
+		fmt.Fprintln(w, "    </edge>")
+	}
+	fmt.Fprintln(w, "  </graph>")
+	fmt.Fprintln(w, "</graphml>")
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// groupTally counts the reads, and the reads where each indicator is 1,
+// separately within each -groupby group. Groups are numbered in the order
+// they are first seen.
+type groupTally struct {
+	names     []string
+	index     map[string]int
+	reads     []int
+	marginals [][]int
+}
+
+// TrackGroups makes the tally count the reads of each group given to
+// AddGroup. It must be called before the first read is added.
+func (t *Tally) TrackGroups() {
+	t.Groups = &groupTally{index: make(map[string]int)}
+}
+
+// AddGroup tallies a read, seen the given number of times, within its
+// group.
+func (t *Tally) AddGroup(row []int, group string, times int) {
+	gt := t.Groups
+	g, ok := gt.index[group]
+	if !ok {
+		g = len(gt.names)
+		gt.index[group] = g
+		gt.names = append(gt.names, group)
+		gt.reads = append(gt.reads, 0)
+		gt.marginals = append(gt.marginals, make([]int, len(row)))
+	}
+	gt.reads[g] += times
+	for i, v := range row {
+		gt.marginals[g][i] += v * times
+	}
+}
+
+// normalPValue returns the two-sided p-value of a standard normal z
+// statistic.
+func normalPValue(z float64) float64 {
+	return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// corrections are the multiple-testing corrections of -correction.
+var corrections = []string{"none", "bonferroni", "bh"}
+
+func validCorrection(method string) bool {
+	for _, m := range corrections {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// adjustPValues corrects p-values for the number of tests, by Bonferroni or
+// by the Benjamini-Hochberg false discovery rate procedure. NaN p-values
+// are left out of the count of tests and stay NaN.
+func adjustPValues(p []float64, method string) []float64 {
+	adjusted := make([]float64, len(p))
+	var order []int
+	for k, v := range p {
+		adjusted[k] = math.NaN()
+		if !math.IsNaN(v) {
+			order = append(order, k)
+		}
+	}
+	m := float64(len(order))
+	switch method {
+	case "bonferroni":
+		for _, k := range order {
+			adjusted[k] = math.Min(1, p[k]*m)
+		}
+	case "bh":
+		sort.SliceStable(order, func(a, b int) bool {
+			return p[order[a]] < p[order[b]]
+		})
+		// Step up from the largest p-value, keeping the adjusted values
+		// monotone
+		running := 1.0
+		for rank := len(order); rank >= 1; rank-- {
+			k := order[rank-1]
+			running = math.Min(running, p[k]*m/float64(rank))
+			adjusted[k] = running
+		}
+	default:
+		copy(adjusted, p)
+	}
+	return adjusted
+}
+
+// checkGroups checks, once the input has been read and before any output
+// is written, that there are the -groupby groups that the -group-diff and
+// -ztest outputs compare.
+func checkGroups(t *Tally) error {
+	if args.GroupDiff != "" && len(t.Groups.names) != 2 {
+		return fmt.Errorf("-group-diff needs exactly two -groupby groups but there are %d", len(t.Groups.names))
+	}
+	if args.ZTest != "" {
+		if _, _, err := zTestGroups(t.Groups); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeGroupDiff compares each indicator's marginal probability between the
+// two -groupby groups, printing the difference between them, its unpooled
+// standard error and the two-sided p-value of the difference, corrected
+// for the number of columns by -correction. It writes nothing while there
+// aren't yet two groups, as in a -flush-interval snapshot of the first
+// reads; checkGroups has made sure there are by the end.
+func writeGroupDiff(w io.Writer, t *Tally, names []string) {
+	gt := t.Groups
+	if len(gt.names) != 2 {
+		return
+	}
+	n1, n2 := float64(gt.reads[0]), float64(gt.reads[1])
+	diffs := make([]float64, len(names))
+	ses := make([]float64, len(names))
+	pValues := make([]float64, len(names))
+	for i := range names {
+		p1 := float64(gt.marginals[0][i]) / n1
+		p2 := float64(gt.marginals[1][i]) / n2
+		diffs[i] = p1 - p2
+		ses[i] = math.Sqrt(p1*(1-p1)/n1 + p2*(1-p2)/n2)
+		pValues[i] = math.NaN()
+		if ses[i] > 0 {
+			pValues[i] = normalPValue(diffs[i] / ses[i])
+		}
+	}
+	fmt.Fprintf(w, "column\tp_%s\tp_%s\tdifference\tse\tp_value", gt.names[0], gt.names[1])
+	var adjusted []float64
+	if args.Correction != "none" {
+		adjusted = adjustPValues(pValues, args.Correction)
+		fmt.Fprint(w, "\tadjusted_p_value")
+	}
+	fmt.Fprintln(w)
+	for i, name := range names {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s", name,
+			formatValue(float64(gt.marginals[0][i])/n1, 'f', 8), formatValue(float64(gt.marginals[1][i])/n2, 'f', 8), formatValue(diffs[i], 'f', 8), formatValue(ses[i], 'f', 8), formatValue(pValues[i], 'g', 8))
+		if adjusted != nil {
+			fmt.Fprintf(w, "\t%s", formatValue(adjusted[i], 'g', 8))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// zTestGroups returns the indexes of the -ztest-groups, or of the only two
+// groups when they aren't named.
+func zTestGroups(gt *groupTally) (int, int, error) {
+	if args.ZTestGroups == "" {
+		if len(gt.names) != 2 {
+			return 0, 0, fmt.Errorf("-ztest needs -ztest-groups to choose two of the %d -groupby groups", len(gt.names))
+		}
+		return 0, 1, nil
+	}
+	var groups [2]int
+	for k, name := range strings.Split(args.ZTestGroups, ",") {
+		g, ok := gt.index[name]
+		if !ok {
+			return 0, 0, fmt.Errorf("there is no -groupby group '%s' for -ztest-groups", name)
+		}
+		groups[k] = g
+	}
+	return groups[0], groups[1], nil
+}
+
+// writeZTest prints the pooled two-sample z-test of whether each
+// indicator's marginal probability is the same in two -groupby groups, with
+// the z statistic and its two-sided p-value, corrected for the number of
+// columns by -correction. Like writeGroupDiff, it writes nothing until the
+// groups have been seen.
+func writeZTest(w io.Writer, t *Tally, names []string) {
+	gt := t.Groups
+	a, b, err := zTestGroups(gt)
+	if err != nil {
+		return
+	}
+	na, nb := float64(gt.reads[a]), float64(gt.reads[b])
+	zs := make([]float64, len(names))
+	pValues := make([]float64, len(names))
+	for i := range names {
+		ka, kb := float64(gt.marginals[a][i]), float64(gt.marginals[b][i])
+		pooled := (ka + kb) / (na + nb)
+		se := math.Sqrt(pooled * (1 - pooled) * (1/na + 1/nb))
+		zs[i], pValues[i] = math.NaN(), math.NaN()
+		if se > 0 {
+			zs[i] = (ka/na - kb/nb) / se
+			pValues[i] = normalPValue(zs[i])
+		}
+	}
+	fmt.Fprint(w, "column\tz\tp_value")
+	var adjusted []float64
+	if args.Correction != "none" {
+		adjusted = adjustPValues(pValues, args.Correction)
+		fmt.Fprint(w, "\tadjusted_p_value")
+	}
+	fmt.Fprintln(w)
+	for i, name := range names {
+		fmt.Fprintf(w, "%s\t%s\t%s", name, formatValue(zs[i], 'f', 8), formatValue(pValues[i], 'g', 8))
+		if adjusted != nil {
+			fmt.Fprintf(w, "\t%s", formatValue(adjusted[i], 'g', 8))
+		}
+		fmt.Fprintln(w)
+	}
+}
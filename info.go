@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// Entropies and mutual informations are measured in bits.
+
+// plogp returns the contribution of a probability p to an entropy, taking
+// 0 log 0 to be 0.
+func plogp(p float64) float64 {
+	if p == 0 {
+		return 0
+	}
+	return p * math.Log2(p)
+}
+
+// Entropy returns the entropy of indicator i.
+func (t *Tally) Entropy(i int) float64 {
+	return bernoulliEntropy(float64(t.Marginals[i]) / float64(t.NumReads))
+}
+
+// MutualInformation returns the mutual information I(A;B) between
+// indicators i and j, computed from their contingency table.
+func (t *Tally) MutualInformation(i, j int) float64 {
+	n11, n10, n01, n00 := t.Contingency(i, j)
+	n := float64(t.NumReads)
+	pi := float64(t.Marginals[i]) / n
+	pj := float64(t.Marginals[j]) / n
+	mi := 0.0
+	cells := []struct {
+		count int
+		pa    float64
+		pb    float64
+	}{
+		{n11, pi, pj},
+		{n10, pi, 1 - pj},
+		{n01, 1 - pi, pj},
+		{n00, 1 - pi, 1 - pj},
+	}
+	for _, cell := range cells {
+		if cell.count == 0 {
+			continue
+		}
+		p := float64(cell.count) / n
+		mi += p * math.Log2(p/(cell.pa*cell.pb))
+	}
+	return mi
+}
+
+// ConditionalEntropy returns the entropy H(A|B) of indicator i that
+// remains once indicator j is known.
+func (t *Tally) ConditionalEntropy(i, j int) float64 {
+	n11, n10, n01, n00 := t.Contingency(i, j)
+	h := 0.0
+	// Weight the entropy of A within each value of B by how often B takes it
+	for _, b := range [][2]int{{n11, n01}, {n10, n00}} {
+		nb := b[0] + b[1]
+		if nb == 0 {
+			continue
+		}
+		p := float64(b[0]) / float64(nb)
+		h -= float64(nb) / float64(t.NumReads) * (plogp(p) + plogp(1-p))
+	}
+	return h
+}
+
+// writeTheilU prints Theil's uncertainty coefficient U(A|B) = I(A;B)/H(A)
+// for every ordered pair of distinct indicators: the fraction of the
+// uncertainty about A that is removed by knowing B. When A is constant it
+// has no uncertainty to remove and U is defined to be 0.
+func writeTheilU(w io.Writer, t *Tally, names []string) {
+	for i, iName := range names {
+		h := t.Entropy(i)
+		for j, jName := range names {
+			if i == j {
+				continue
+			}
+			mi := t.MutualInformation(i, j)
+			u := 0.0
+			if h > 0 {
+				u = mi / h
+			}
+			fmt.Fprintf(w, "U( %s | %s ) = %s ; %s , %s\n", iName, jName, formatValue(u, 'f', 8), formatValue(mi, 'f', 8), formatValue(h, 'f', 8))
+		}
+	}
+}
+
+// writeInfoGain prints the information gain H(A) - H(A|B) about A from
+// knowing B for every ordered pair of distinct indicators, along with the
+// two entropies.
+func writeInfoGain(w io.Writer, t *Tally, names []string) {
+	for i, iName := range names {
+		h := t.Entropy(i)
+		for j, jName := range names {
+			if i == j {
+				continue
+			}
+			hc := t.ConditionalEntropy(i, j)
+			fmt.Fprintf(w, "IG( %s | %s ) = %s ; %s , %s\n", iName, jName, formatValue(h-hc, 'f', 8), formatValue(h, 'f', 8), formatValue(hc, 'f', 8))
+		}
+	}
+}
+
+// bernoulliKL returns the Kullback-Leibler divergence D(P||Q) between
+// Bernoulli distributions with success probabilities p and q. It is +Inf
+// when q gives zero probability to an outcome that p doesn't.
+func bernoulliKL(p, q float64) float64 {
+	d := 0.0
+	for _, o := range [][2]float64{{p, q}, {1 - p, 1 - q}} {
+		if o[0] == 0 {
+			continue
+		}
+		if o[1] == 0 {
+			return math.Inf(1)
+		}
+		d += o[0] * math.Log2(o[0]/o[1])
+	}
+	return d
+}
+
+// writeKL prints the Kullback-Leibler divergence D(P_A || P_B) between the
+// Bernoulli distributions of every ordered pair of distinct indicators,
+// along with their marginal probabilities. The divergence is written as
+// +Inf when B's marginal is 0 or 1 and A's differs from it.
+func writeKL(w io.Writer, t *Tally, names []string) {
+	n := float64(t.NumReads)
+	for i, iName := range names {
+		pi := float64(t.Marginals[i]) / n
+		for j, jName := range names {
+			if i == j {
+				continue
+			}
+			pj := float64(t.Marginals[j]) / n
+			fmt.Fprintf(w, "KL( %s || %s ) = %s ; %s , %s\n", iName, jName, formatValue(bernoulliKL(pi, pj), 'f', 8), formatValue(pi, 'f', 8), formatValue(pj, 'f', 8))
+		}
+	}
+}
+
+// bernoulliEntropy returns the entropy of a Bernoulli distribution with
+// success probability p.
+func bernoulliEntropy(p float64) float64 {
+	return -plogp(p) - plogp(1-p)
+}
+
+// writeJS prints the Jensen-Shannon divergence between the Bernoulli
+// distributions of every pair of indicators: the entropy of their mixture
+// less the mean of their entropies. It is symmetric and lies between 0 and 1.
+func writeJS(w io.Writer, t *Tally, names []string) {
+	n := float64(t.NumReads)
+	for i, iName := range names {
+		pi := float64(t.Marginals[i]) / n
+		for j := i + 1; j < len(names); j++ {
+			pj := float64(t.Marginals[j]) / n
+			js := bernoulliEntropy((pi+pj)/2) - (bernoulliEntropy(pi)+bernoulliEntropy(pj))/2
+			fmt.Fprintf(w, "JS( %s , %s ) = %s ; %s , %s\n", iName, names[j], formatValue(js, 'f', 8), formatValue(pi, 'f', 8), formatValue(pj, 'f', 8))
+		}
+	}
+}
+
+// writeMIRank prints every other indicator with its mutual information with
+// the -target, most informative first.
+func writeMIRank(w io.Writer, t *Tally, names []string) {
+	target := t.Target
+	var ranked []edge
+	for j := range names {
+		if j != target {
+			ranked = append(ranked, edge{target, j, t.MutualInformation(target, j)})
+		}
+	}
+	sort.SliceStable(ranked, func(a, b int) bool {
+		return ranked[a].weight > ranked[b].weight
+	})
+	fmt.Fprintln(w, "column\tmi")
+	for _, e := range ranked {
+		fmt.Fprintf(w, "%s\t%s\n", names[e.to], formatValue(e.weight, 'f', 8))
+	}
+}
@@ -0,0 +1,403 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// A weightedReader also gives the -weightcol weight of each read.
+type weightedReader interface {
+	Weight() float64
+}
+
+// A missingCounter also counts the -missing-value cells of each column.
+type missingCounter interface {
+	MissingCounts() []int64
+}
+
+// A groupedReader also gives the -groupby group of each read.
+type groupedReader interface {
+	Group() string
+}
+
+// A multiplicityReader also gives the number of times each distinct row
+// occurs, for -multiplicity.
+type multiplicityReader interface {
+	Multiplicity() int
+}
+
+// A rowReader yields the rows of an indicator matrix one read at a time. The
+// indicator column names are known as soon as the reader is constructed. The
+// slice returned by Row is reused between calls to Scan.
+type rowReader interface {
+	Fields() []string
+	Scan() bool
+	Read() string
+	Row() []int
+	Err() error
+}
+
+// openInput opens the -input file, or returns stdin if there is none. A
+// named pipe can be given as the -input to read a stream that is still being
+// written. With -mmap a regular file is memory-mapped instead of being read
+// through the kernel, falling back to reading it normally if it can't be.
+func openInput() (io.ReadCloser, error) {
+	in, err := openInputFile()
+	if err != nil || args.Manifest == "" {
+		return in, err
+	}
+	inputHash = newHashingReader(in)
+	return inputHash, nil
+}
+
+func openInputFile() (io.ReadCloser, error) {
+	if args.Input == "" {
+		return os.Stdin, nil
+	}
+	fp, err := os.Open(args.Input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open input '%s': %v", args.Input, err)
+	}
+	if args.Mmap {
+		mapped, err := mmapFile(fp)
+		if err == nil {
+			return mapped, nil
+		}
+		verbosef("reading input '%s' without memory mapping: %v\n", args.Input, err)
+	}
+	return fp, nil
+}
+
+// openReader returns a reader for the input in the format chosen by the
+// options, keeping only one occurrence of each read with -dedup or
+// -dedup-agg, or of each row with -unique or -multiplicity.
+func openReader(in io.Reader) (rowReader, error) {
+	reader, err := openFormatReader(in)
+	if err != nil {
+		return nil, err
+	}
+	return withDedup(reader), nil
+}
+
+// withDedup wraps a reader to drop or combine the repeated reads or rows as
+// the options say, if they do.
+func withDedup(reader rowReader) rowReader {
+	if args.Dedup != "" {
+		return newDedupReader(reader, args.Dedup)
+	}
+	if args.DedupAgg != "" {
+		return newDedupReader(reader, args.DedupAgg)
+	}
+	if args.Unique {
+		return newDedupReader(reader, "unique")
+	}
+	if args.Multiplicity {
+		return newDedupReader(reader, "multiplicity")
+	}
+	return reader
+}
+
+func openFormatReader(in io.Reader) (rowReader, error) {
+	if args.Long {
+		return newLongReader(in, args.Limit)
+	} else if args.Sparse != "" {
+		return newSparseReader(in, args.Sparse, args.Limit)
+	} else if args.Onehot != "" {
+		return newOnehotReader(in, args.Limit, args.Onehot)
+	} else if args.Threads > 1 {
+		return newParallelWideReader(in, args.Limit, args.Threads)
+	}
+	return newWideReader(in, args.Limit)
+}
+
+// wideReader parses the default input format: a header line whose first
+// column is 'read', followed by one line per read giving the 0/1 values of
+// each indicator variable.
+type wideReader struct {
+	scanner    *bufio.Scanner
+	limit      int
+	lineNum    int
+	fieldNames []string
+	layout     wideLayout
+	// fields and row are reused for every line to avoid allocating per read
+	fields [][]byte
+	row    []int
+	weight float64
+	err    error
+}
+
+// A wideLayout describes how the fields of a line of wide format input after
+// the read name map to the indicator values: the name and threshold of each
+// indicator and the positions of the -weightcol and -groupby columns, which
+// aren't indicators, or -1 for those there aren't.
+type wideLayout struct {
+	names      []string
+	thresholds []float64
+	// missing counts the -missing-value cells of each indicator
+	missing   []int64
+	weightCol int
+	groupCol  int
+}
+
+func newWideReader(r io.Reader, limit int) (*wideReader, error) {
+	wr := &wideReader{
+		scanner: bufio.NewScanner(r),
+		limit:   limit,
+	}
+	if !wr.scanner.Scan() {
+		return wr, wr.scanner.Err()
+	}
+	wr.lineNum++
+	// This should be the header line
+	fields := splitHeader(wr.scanner.Text())
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("too few fields")
+	}
+	if fields[0] != "read" {
+		return nil, fmt.Errorf("first field should be named 'read'")
+	}
+	wr.layout.weightCol, wr.layout.groupCol = -1, -1
+	for i, name := range fields[1:] {
+		name = columnName(name)
+		if args.WeightCol != "" && name == args.WeightCol {
+			wr.layout.weightCol = i
+		} else if args.GroupBy != "" && name == args.GroupBy {
+			wr.layout.groupCol = i
+		} else {
+			wr.fieldNames = append(wr.fieldNames, name)
+		}
+	}
+	if args.WeightCol != "" && wr.layout.weightCol < 0 {
+		return nil, fmt.Errorf("there is no -weightcol column '%s'", args.WeightCol)
+	}
+	if args.GroupBy != "" && wr.layout.groupCol < 0 {
+		return nil, fmt.Errorf("there is no -groupby column '%s'", args.GroupBy)
+	}
+	if err := checkDuplicateNames(wr.fieldNames); err != nil {
+		return nil, err
+	}
+	wr.layout.names = wr.fieldNames
+	wr.layout.thresholds = thresholdsFor(wr.fieldNames)
+	wr.layout.missing = make([]int64, len(wr.fieldNames))
+	wr.row = make([]int, len(wr.fieldNames))
+	return wr, nil
+}
+
+func (wr *wideReader) Fields() []string {
+	return wr.fieldNames
+}
+
+func (wr *wideReader) Scan() bool {
+	if wr.err != nil || wr.fieldNames == nil {
+		return false
+	}
+	if wr.limit > 0 && wr.lineNum > wr.limit {
+		return false
+	}
+	for {
+		if !wr.scanner.Scan() {
+			wr.err = wr.scanner.Err()
+			return false
+		}
+		wr.lineNum++
+		// This should be a line naming the read and giving the values of the indicator variables
+		var err error
+		wr.fields, wr.weight, err = parseWideLine(wr.scanner.Bytes(), wr.fields, wr.row, &wr.layout, wr.lineNum)
+		if err == nil {
+			return true
+		}
+		if !badRows.tolerate(err) {
+			wr.err = err
+			return false
+		}
+		if wr.limit > 0 && wr.lineNum > wr.limit {
+			return false
+		}
+	}
+}
+
+// Weight returns the -weightcol weight of the read, or 1 without one.
+func (wr *wideReader) Weight() float64 {
+	return wr.weight
+}
+
+// MissingCounts returns the number of -missing-value cells of each
+// indicator read so far.
+func (wr *wideReader) MissingCounts() []int64 {
+	return wr.layout.missing
+}
+
+// Group returns the read's -groupby group.
+func (wr *wideReader) Group() string {
+	return string(wr.fields[wr.layout.groupCol+1])
+}
+
+func (wr *wideReader) Read() string {
+	return string(wr.fields[0])
+}
+
+func (wr *wideReader) Row() []int {
+	return wr.row
+}
+
+func (wr *wideReader) Err() error {
+	return wr.err
+}
+
+// splitHeader splits a header line into its tab-separated column names. A
+// name in double quotes is unquoted, with any doubled quote inside it
+// taken as a single one as in CSV, so that names with spaces can be quoted.
+func splitHeader(line string) []string {
+	names := strings.Split(line, "\t")
+	for i, name := range names {
+		if len(name) >= 2 && name[0] == '"' && name[len(name)-1] == '"' {
+			names[i] = strings.ReplaceAll(name[1:len(name)-1], `""`, `"`)
+		}
+	}
+	return names
+}
+
+// columnName normalizes a column name as it is read, lowercasing it with
+// -lowercase-columns.
+func columnName(name string) string {
+	if args.LowercaseColumns {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+// checkDuplicateNames returns an error naming the first column name that
+// appears more than once in a header.
+func checkDuplicateNames(names []string) error {
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] {
+			return fmt.Errorf("column '%s' appears more than once in the header", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+// splitTabs splits a line into its tab-separated fields, reusing the
+// backing storage of fields. The fields share the line's storage, so they
+// are only valid until the line is overwritten.
+func splitTabs(line []byte, fields [][]byte) [][]byte {
+	fields = fields[:0]
+	for {
+		i := bytes.IndexByte(line, '\t')
+		if i < 0 {
+			return append(fields, line)
+		}
+		fields = append(fields, line[:i])
+		line = line[i+1:]
+	}
+}
+
+// zeroField is what -pad fills out short lines with.
+var zeroField = []byte("0")
+
+// parseWideLine splits a line of wide format input into its fields, reusing
+// the storage of fields, and parses the indicator values into row. With
+// -pad a line with fewer fields than the header is filled out with zeros.
+// It returns the read's weight as well, which is 1 unless the layout has a
+// weight column. Every invalid cell of the line is reported, each error
+// joined into the one returned.
+func parseWideLine(line []byte, fields [][]byte, row []int, layout *wideLayout, lineNum int) ([][]byte, float64, error) {
+	fields = splitTabs(line, fields)
+	numFields := len(row) + 1
+	if layout.weightCol >= 0 {
+		numFields++
+	}
+	if layout.groupCol >= 0 {
+		numFields++
+	}
+	if args.Pad && len(fields[0]) > 0 && len(fields) < numFields {
+		badRows.pad()
+		for len(fields) < numFields {
+			fields = append(fields, zeroField)
+		}
+	}
+	if len(fields) != numFields {
+		return fields, 0, fmt.Errorf("expected line %d to have %d fields", lineNum, numFields)
+	}
+	weight := 1.0
+	i := 0
+	var cellErrs []error
+	for k, field := range fields[1:] {
+		if k == layout.weightCol {
+			var err error
+			weight, err = strconv.ParseFloat(string(field), 64)
+			if err != nil || !(weight >= 0) || math.IsInf(weight, 1) {
+				cellErrs = append(cellErrs, fmt.Errorf("invalid weight '%s' on line %d", field, lineNum))
+			}
+			continue
+		}
+		if k == layout.groupCol {
+			continue
+		}
+		if args.MissingValue != "" && string(field) == args.MissingValue {
+			// Missing cells are tallied as 0, but counted
+			atomic.AddInt64(&layout.missing[i], 1)
+			row[i] = 0
+			i++
+			continue
+		}
+		val, err := parseIndicator(field, layout.thresholds[i], lineNum)
+		if err != nil {
+			cellErrs = append(cellErrs, fmt.Errorf("column '%s': %v", layout.names[i], err))
+		}
+		row[i] = val
+		i++
+	}
+	if cellErrs != nil {
+		return fields, 0, errors.Join(cellErrs...)
+	}
+	return fields, weight, nil
+}
+
+// parseIndicator converts a single 0/1 cell value. With -relaxed a number
+// equal to 0 or 1 written some other way, such as 1.0, is accepted too. With
+// -binarize any number is accepted, and any positive one is taken to be 1.
+// A column with a threshold that isn't NaN accepts any number, and takes
+// those at least the threshold to be 1.
+func parseIndicator(val []byte, threshold float64, lineNum int) (int, error) {
+	if !math.IsNaN(threshold) {
+		f, err := strconv.ParseFloat(string(val), 64)
+		if err != nil || math.IsNaN(f) {
+			return 0, fmt.Errorf("invalid number '%s' on line %d", val, lineNum)
+		}
+		if f >= threshold {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	if len(val) == 1 && val[0] == '0' {
+		return 0, nil
+	} else if len(val) == 1 && val[0] == '1' {
+		return 1, nil
+	}
+	if args.Binarize {
+		f, err := strconv.ParseFloat(string(val), 64)
+		if err == nil && f < 0 {
+			return 0, fmt.Errorf("negative value '%s' on line %d", val, lineNum)
+		} else if err == nil && f > 0 {
+			return 1, nil
+		} else if err == nil && f == 0 {
+			return 0, nil
+		}
+	} else if args.Relaxed {
+		if f, err := strconv.ParseFloat(string(val), 64); err == nil && (f == 0 || f == 1) {
+			return int(f), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid value '%s' on line %d", val, lineNum)
+}
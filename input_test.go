@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// wideInput builds a wide format matrix with a checkerboard of values.
+func wideInput(numReads, numFields int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("read")
+	for j := 0; j < numFields; j++ {
+		fmt.Fprintf(&buf, "\tc%d", j)
+	}
+	buf.WriteString("\n")
+	for i := 0; i < numReads; i++ {
+		fmt.Fprintf(&buf, "r%d", i)
+		for j := 0; j < numFields; j++ {
+			fmt.Fprintf(&buf, "\t%d", (i+j)%2)
+		}
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
+
+// BenchmarkWideReader measures parsing alone. The fields of each line are
+// split into reused storage, so the allocations per op should stay roughly
+// constant as the number of reads grows.
+func BenchmarkWideReader(b *testing.B) {
+	for _, numReads := range []int{1000, 10000} {
+		input := wideInput(numReads, 100)
+		b.Run(fmt.Sprintf("reads=%d", numReads), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(input)))
+			for n := 0; n < b.N; n++ {
+				wr, err := newWideReader(bytes.NewReader(input), 0)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for wr.Scan() {
+				}
+				if err := wr.Err(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(testing.AllocsPerRun(1, func() {
+				wr, _ := newWideReader(bytes.NewReader(input), 0)
+				for wr.Scan() {
+				}
+			}))/float64(numReads), "allocs/read")
+		})
+	}
+}
+
+// BenchmarkParallelWideReader measures parsing with different numbers of
+// -threads, which should scale with the number of cores available.
+func BenchmarkParallelWideReader(b *testing.B) {
+	input := wideInput(10000, 100)
+	for _, threads := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("threads=%d", threads), func(b *testing.B) {
+			b.SetBytes(int64(len(input)))
+			for n := 0; n < b.N; n++ {
+				var reader rowReader
+				var err error
+				if threads == 1 {
+					reader, err = newWideReader(bytes.NewReader(input), 0)
+				} else {
+					reader, err = newParallelWideReader(bytes.NewReader(input), 0, threads)
+				}
+				if err != nil {
+					b.Fatal(err)
+				}
+				for reader.Scan() {
+				}
+				if err := reader.Err(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// FuzzWideReader feeds arbitrary input to the wide format parser, checking
+// that it never panics, that every line it accepts has a value for each
+// column, and that it ends either at the end of the input or with an error
+// saying what is wrong. The parallel parser must read the same rows and
+// stop in the same way.
+func FuzzWideReader(f *testing.F) {
+	f.Add(wideInput(3, 4))
+	f.Add([]byte("read\ta\tb\nr1\t1\t0\nr2\t0\n"))
+	f.Add([]byte("read\ta\tb\nr1\t1\tx\n"))
+	f.Add([]byte("read\t\"a\"\"b\"\tc\r\nr1\t1\t1\r\n"))
+	f.Add([]byte("read\ta\ta\n"))
+	f.Add([]byte(""))
+	f.Fuzz(func(t *testing.T, input []byte) {
+		resetArgs(t)
+		serial, serialErr := readAllRows(newWideReader(bytes.NewReader(input), 0))
+		parallel, parallelErr := readAllRows(newParallelWideReader(bytes.NewReader(input), 0, 2))
+		if (serialErr == nil) != (parallelErr == nil) {
+			t.Fatalf("the serial parser stopped with %v but the parallel one with %v", serialErr, parallelErr)
+		}
+		if serialErr != nil && serialErr.Error() == "" {
+			t.Fatal("stopped with an empty error")
+		}
+		if !equalRows(serial, parallel) {
+			t.Fatalf("the serial parser read %v but the parallel one %v", serial, parallel)
+		}
+	})
+}
+
+// readAllRows reads every row from a newly opened reader, checking that
+// each has a value for every column.
+func readAllRows(reader rowReader, err error) ([][]int, error) {
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]int
+	for reader.Scan() {
+		if len(reader.Row()) != len(reader.Fields()) {
+			return rows, fmt.Errorf("read a row of %d values for %d columns", len(reader.Row()), len(reader.Fields()))
+		}
+		rows = append(rows, append([]int(nil), reader.Row()...))
+	}
+	return rows, reader.Err()
+}
+
+func equalRows(a, b [][]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if fmt.Sprint(a[i]) != fmt.Sprint(b[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// An itemset is a set of columns, in increasing order, with the reads
+// where all of them are 1.
+type itemset struct {
+	cols  []int
+	reads bitset
+	count int
+}
+
+// frequentItemsets finds every set of up to -max-itemset-size columns that
+// are all 1 together in at least -minsupport reads, and at least one, by
+// Apriori: the candidates of each size are joined from pairs of frequent
+// sets one smaller that differ only in their last column, and pruned unless
+// every subset one smaller is frequent, before their reads are counted. The
+// sets are returned smallest first, and in column order within each size.
+func frequentItemsets(t *Tally) []itemset {
+	minCount := args.MinSupport
+	if minCount < 1 {
+		minCount = 1
+	}
+	var level []itemset
+	for i, col := range t.Columns {
+		if t.Marginals[i] >= minCount {
+			level = append(level, itemset{cols: []int{i}, reads: col, count: t.Marginals[i]})
+		}
+	}
+	all := append([]itemset(nil), level...)
+	for size := 2; size <= args.MaxItemsetSize && len(level) > 1; size++ {
+		frequent := make(map[string]bool, len(level))
+		for _, s := range level {
+			frequent[itemsetKey(s.cols)] = true
+		}
+		var next []itemset
+		for a := range level {
+			for b := a + 1; b < len(level); b++ {
+				prefix := level[a].cols[:size-2]
+				if !equalInts(prefix, level[b].cols[:size-2]) {
+					// The sets are in order, so no later one shares the prefix
+					break
+				}
+				cols := append(append([]int(nil), level[a].cols...), level[b].cols[size-2])
+				if !allSubsetsFrequent(cols, frequent) {
+					continue
+				}
+				reads := make(bitset, len(level[a].reads))
+				for k := range reads {
+					reads[k] = level[a].reads[k] & level[b].reads[k]
+				}
+				if count := andCount(reads, reads); count >= minCount {
+					next = append(next, itemset{cols: cols, reads: reads, count: count})
+				}
+			}
+		}
+		all = append(all, next...)
+		level = next
+	}
+	return all
+}
+
+// allSubsetsFrequent reports whether every subset of cols with one column
+// left out is among the frequent sets.
+func allSubsetsFrequent(cols []int, frequent map[string]bool) bool {
+	subset := make([]int, 0, len(cols)-1)
+	for skip := range cols {
+		subset = subset[:0]
+		for k, c := range cols {
+			if k != skip {
+				subset = append(subset, c)
+			}
+		}
+		if !frequent[itemsetKey(subset)] {
+			return false
+		}
+	}
+	return true
+}
+
+func itemsetKey(cols []int) string {
+	return fmt.Sprint(cols)
+}
+
+func equalInts(a, b []int) bool {
+	for k := range a {
+		if a[k] != b[k] {
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
+
+// itemsetNames joins the names of an itemset's columns with commas.
+func itemsetNames(s itemset, names []string) string {
+	itemNames := make([]string, len(s.cols))
+	for k, c := range s.cols {
+		itemNames[k] = names[c]
+	}
+	return strings.Join(itemNames, ",")
+}
+
+// writeItemsets prints the frequent itemsets with the number and
+// proportion of reads where all of their columns are 1.
+func writeItemsets(w io.Writer, t *Tally, names []string) {
+	fmt.Fprintln(w, "itemset\tsize\tcount\tprobability")
+	for _, s := range frequentItemsets(t) {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", itemsetNames(s, names), len(s.cols), s.count,
+			formatProb(float64(s.count)/float64(t.NumReads), 8))
+	}
+}
+
+// closedItemsets returns the frequent itemsets that have no superset that
+// is 1 in as many reads. A superset with the same count would have a
+// superset one column larger with the same count too, so only those need
+// to be checked. Sets of -max-itemset-size columns have no mined supersets
+// to compare with, so they are all taken to be closed.
+func closedItemsets(sets []itemset) []itemset {
+	index := make(map[string]int, len(sets))
+	for k, s := range sets {
+		index[itemsetKey(s.cols)] = k
+	}
+	notClosed := make([]bool, len(sets))
+	subset := []int{}
+	for _, s := range sets {
+		for skip := range s.cols {
+			if len(s.cols) == 1 {
+				break
+			}
+			subset = subset[:0]
+			for k, c := range s.cols {
+				if k != skip {
+					subset = append(subset, c)
+				}
+			}
+			if k, ok := index[itemsetKey(subset)]; ok && sets[k].count == s.count {
+				notClosed[k] = true
+			}
+		}
+	}
+	var closed []itemset
+	for k, s := range sets {
+		if !notClosed[k] {
+			closed = append(closed, s)
+		}
+	}
+	return closed
+}
+
+// writeClosedItemsets prints the closed frequent itemsets with the number
+// and proportion of reads where all of their columns are 1.
+func writeClosedItemsets(w io.Writer, t *Tally, names []string) {
+	fmt.Fprintln(w, "itemset\tsize\tcount\tprobability")
+	for _, s := range closedItemsets(frequentItemsets(t)) {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", itemsetNames(s, names), len(s.cols), s.count,
+			formatProb(float64(s.count)/float64(t.NumReads), 8))
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// All diagnostics go through these functions so that they can be written
+// either as plain text or, with -log-json, as one JSON object per line.
+// Informational messages are logged unless -q is given, and verbose ones
+// such as timings only with -v. Warnings and errors are always logged.
+
+// logFields are typed values attached to a message in JSON logs.
+type logFields map[string]interface{}
+
+type logEntry struct {
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+	Fields  logFields `json:"fields,omitempty"`
+}
+
+func logAt(level string, fields logFields, format string, v ...interface{}) {
+	message := fmt.Sprintf(format, v...)
+	if !args.LogJSON {
+		log.Print(message)
+		return
+	}
+	b, err := json.Marshal(logEntry{level, strings.TrimRight(message, "\n"), fields})
+	if err != nil {
+		// Only unmarshalable fields can cause this, so drop them
+		b, _ = json.Marshal(logEntry{level, strings.TrimRight(message, "\n"), nil})
+	}
+	log.Println(string(b))
+}
+
+func infof(format string, v ...interface{}) {
+	infow(nil, format, v...)
+}
+
+func infow(fields logFields, format string, v ...interface{}) {
+	if !args.Quiet {
+		logAt("info", fields, format, v...)
+	}
+}
+
+func verbosef(format string, v ...interface{}) {
+	verbosew(nil, format, v...)
+}
+
+func verbosew(fields logFields, format string, v ...interface{}) {
+	if args.Verbose && !args.Quiet {
+		logAt("verbose", fields, format, v...)
+	}
+}
+
+func warnf(format string, v ...interface{}) {
+	logAt("warning", nil, format, v...)
+}
+
+func errorf(format string, v ...interface{}) {
+	logAt("error", nil, format, v...)
+}
+
+// logFile is the -logfile that diagnostics are written to instead of
+// stderr, if there is one.
+var logFile *os.File
+
+// openLogFile sends every diagnostic to the -logfile, appending to it with
+// -logfile-append.
+func openLogFile(path string, appendTo bool) error {
+	mode := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendTo {
+		mode = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	fp, err := os.OpenFile(path, mode, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file '%s': %v", path, err)
+	}
+	logFile = fp
+	log.SetOutput(fp)
+	return nil
+}
+
+// fatalf logs an error and exits. With a -logfile the error is written to
+// stderr as well, so that it isn't missed.
+func fatalf(format string, v ...interface{}) {
+	errorf(format, v...)
+	if logFile != nil {
+		fmt.Fprintf(os.Stderr, format, v...)
+	}
+	os.Exit(1)
+}
+
+// fatal logs its operands like log.Fatalln and exits.
+func fatal(v ...interface{}) {
+	fatalf("%s", fmt.Sprintln(v...))
+}
+
+// since returns the time elapsed since start, rounded for logging.
+func since(start time.Time) time.Duration {
+	return time.Since(start).Round(time.Microsecond)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// longReader parses long (tidy) format input, where each line after the
+// 'read\tvariable\tvalue' header gives a single observation. The whole input
+// is read up front to discover the set of variables and reads, which are
+// then served in the order they were first seen. Any (read, variable)
+// combination that is never observed is taken to be 0.
+type longReader struct {
+	fieldNames []string
+	reads      []string
+	// positives[r] lists the indices of the variables that are 1 for read r
+	positives [][]int
+	pos       int
+	row       []int
+}
+
+type longCell struct {
+	read     int
+	variable int
+}
+
+func newLongReader(r io.Reader, limit int) (*longReader, error) {
+	lr := &longReader{pos: -1}
+	scanner := bufio.NewScanner(r)
+	readIndex := make(map[string]int)
+	varIndex := make(map[string]int)
+	seen := make(map[longCell]int)
+	lineNum := 0
+	for scanner.Scan() {
+		if limit > 0 && lineNum > limit {
+			break
+		}
+		line := scanner.Text()
+		lineNum++
+		fields := strings.Split(line, "\t")
+		if lineNum == 1 {
+			// This should be the header line
+			if len(fields) != 3 {
+				return nil, fmt.Errorf("long format header should have 3 fields")
+			}
+			if fields[0] != "read" {
+				return nil, fmt.Errorf("first field should be named 'read'")
+			}
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("expected line %d to have 3 fields", lineNum)
+		}
+		fields[1] = columnName(fields[1])
+		val, err := parseIndicator([]byte(fields[2]), columnThreshold(fields[1]), lineNum)
+		if err != nil {
+			return nil, err
+		}
+		ri, ok := readIndex[fields[0]]
+		if !ok {
+			ri = len(lr.reads)
+			readIndex[fields[0]] = ri
+			lr.reads = append(lr.reads, fields[0])
+			lr.positives = append(lr.positives, nil)
+		}
+		vi, ok := varIndex[fields[1]]
+		if !ok {
+			vi = len(lr.fieldNames)
+			varIndex[fields[1]] = vi
+			lr.fieldNames = append(lr.fieldNames, fields[1])
+		}
+		cell := longCell{ri, vi}
+		if prev, ok := seen[cell]; ok {
+			return nil, fmt.Errorf("line %d repeats the observation of '%s' for read '%s' from line %d",
+				lineNum, fields[1], fields[0], prev)
+		}
+		seen[cell] = lineNum
+		if val == 1 {
+			lr.positives[ri] = append(lr.positives[ri], vi)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	lr.row = make([]int, len(lr.fieldNames))
+	return lr, nil
+}
+
+func (lr *longReader) Fields() []string {
+	return lr.fieldNames
+}
+
+func (lr *longReader) Scan() bool {
+	if lr.pos+1 >= len(lr.reads) {
+		return false
+	}
+	lr.pos++
+	for i := range lr.row {
+		lr.row[i] = 0
+	}
+	for _, vi := range lr.positives[lr.pos] {
+		lr.row[vi] = 1
+	}
+	return true
+}
+
+func (lr *longReader) Read() string {
+	return lr.reads[lr.pos]
+}
+
+func (lr *longReader) Row() []int {
+	return lr.row
+}
+
+func (lr *longReader) Err() error {
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// readRow fills row with the values of read r from the column bitsets.
+func (t *Tally) readRow(r int, row []int) {
+	for i := range t.Columns {
+		row[i] = 0
+		if t.Columns[i].get(r) {
+			row[i] = 1
+		}
+	}
+}
+
+// smoothedLog returns the log of the smoothed proportion count/total, where
+// the count is one of k possible outcomes, adding -smoothing pseudo-counts
+// to each outcome.
+func smoothedLog(count, total, k int) float64 {
+	return math.Log(smoothedProb(count, total, k))
+}
+
+// looPredict returns the naive Bayes probability that column target of the
+// given row is 1 given its other columns, using counts from which the row
+// itself has been removed.
+func looPredict(t *Tally, row []int, target int) float64 {
+	n := t.NumReads - 1
+	// counts of the target's values with this read left out
+	nt := [2]int{n - (t.Marginals[target] - row[target]), t.Marginals[target] - row[target]}
+	logPost := [2]float64{smoothedLog(nt[0], n, 2), smoothedLog(nt[1], n, 2)}
+	for j := range row {
+		if j == target {
+			continue
+		}
+		// reads with target = 1 and j = 1, and with target = 0 and j = 1
+		n11 := t.Joints[target][j] - row[target]*row[j]
+		n01 := t.Marginals[j] - t.Joints[target][j] - (1-row[target])*row[j]
+		ones := [2]int{n01, n11}
+		for a := 0; a < 2; a++ {
+			count := ones[a]
+			if row[j] == 0 {
+				count = nt[a] - ones[a]
+			}
+			logPost[a] += smoothedLog(count, nt[a], 2)
+		}
+	}
+	// P(target = 1) = 1 / (1 + exp(log P(0) - log P(1)))
+	return 1 / (1 + math.Exp(logPost[0]-logPost[1]))
+}
+
+// writeLOO prints, for every read and column, the leave-one-out naive Bayes
+// prediction of the column's value from the read's other columns alongside
+// the actual value.
+func writeLOO(w io.Writer, t *Tally, names []string) {
+	row := make([]int, len(names))
+	fmt.Fprintln(w, "read\tcolumn\tpredicted\tactual")
+	for r := 0; r < t.NumReads; r++ {
+		t.readRow(r, row)
+		for i, name := range names {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", t.Reads[r], name, formatValue(looPredict(t, row, i), 'f', 8), row[i])
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// version is the version of matrixprobs recorded in the -manifest. Release
+// builds set it with -ldflags "-X main.version=...".
+var version = "dev"
+
+// inputHash hashes the input as it is read when a -manifest is wanted, so
+// that stdin and named pipes can be recorded too.
+var inputHash *hashingReader
+
+// hashingReader hashes and counts the bytes read through it.
+type hashingReader struct {
+	io.ReadCloser
+	hash hash.Hash
+	n    int64
+}
+
+func newHashingReader(r io.ReadCloser) *hashingReader {
+	return &hashingReader{ReadCloser: r, hash: sha256.New()}
+}
+
+func (hr *hashingReader) Read(p []byte) (int, error) {
+	n, err := hr.ReadCloser.Read(p)
+	hr.hash.Write(p[:n])
+	hr.n += int64(n)
+	return n, err
+}
+
+// A manifestFile is an input file recorded in the -manifest.
+type manifestFile struct {
+	Path   string `json:"path"`
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+// A manifest describes a run in enough detail to audit and reproduce it.
+type manifest struct {
+	Version string            `json:"version"`
+	Flags   map[string]string `json:"flags"`
+	Inputs  []manifestFile    `json:"inputs"`
+	Reads   int               `json:"reads"`
+	Fields  int               `json:"fields"`
+	Outputs []string          `json:"outputs"`
+}
+
+// hashFile returns the size and SHA-256 hash of a file.
+func hashFile(path string) (manifestFile, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return manifestFile{}, err
+	}
+	defer fp.Close()
+	hr := newHashingReader(fp)
+	if _, err := io.Copy(io.Discard, hr); err != nil {
+		return manifestFile{}, fmt.Errorf("failed to read '%s': %v", path, err)
+	}
+	return manifestFile{Path: path, Bytes: hr.n, SHA256: hex.EncodeToString(hr.hash.Sum(nil))}, nil
+}
+
+// writeManifest writes the -manifest of a successful run: the input and the
+// other files it read, the flags that were given, the size of the matrix
+// and the outputs written.
+func writeManifest(path string, t *Tally) error {
+	m := manifest{
+		Version: version,
+		Flags:   make(map[string]string),
+		Inputs:  []manifestFile{},
+		Reads:   t.NumReads,
+		Fields:  len(t.FieldNames),
+		Outputs: []string{},
+	}
+	flag.Visit(func(f *flag.Flag) {
+		m.Flags[f.Name] = f.Value.String()
+	})
+	inputPath := args.Input
+	if inputPath == "" {
+		inputPath = "-"
+	}
+	m.Inputs = append(m.Inputs, manifestFile{Path: inputPath, Bytes: inputHash.n,
+		SHA256: hex.EncodeToString(inputHash.hash.Sum(nil))})
+	others := []string{args.Config, args.Sparse, args.Aliases, args.Pairs, args.Thresholds}
+	if args.Resume {
+		others = append(others, args.Checkpoint)
+	}
+	for _, p := range others {
+		if p == "" {
+			continue
+		}
+		input, err := hashFile(p)
+		if err != nil {
+			return fmt.Errorf("failed to hash '%s' for the manifest: %v", p, err)
+		}
+		m.Inputs = append(m.Inputs, input)
+	}
+	for _, out := range selectedOutputs() {
+		m.Outputs = append(m.Outputs, *out.path)
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -4,32 +4,388 @@ package main
  * read matrix of indicator variables. The input is provided on stdin. The first
  * row must be the column names. The first column must be labeled 'read' and must
  * give the names of the reads. All the other columns must be 0 or 1 indicator
- * variable columns. */
+ * variable columns. With -long, the input instead gives one observation per
+ * line as read, variable, and value columns, and with -sparse it lists only
+ * the read and column of each cell that is 1. */
 
 import (
-	"bufio"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"math/rand"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
 type Args struct {
-	Limit        int
-	Marginals    string
-	Conditionals string
-	Joints       string
+	Limit             int
+	Marginals         string
+	Conditionals      string
+	Joints            string
+	Long              bool
+	Sparse            string
+	Aliases           string
+	MCC               string
+	PhiMax            bool
+	Yule              string
+	ChiSquare         string
+	Yates             bool
+	Tetrachoric       string
+	Enrichment        string
+	TopEnriched       int
+	TheilU            string
+	Lambda            string
+	SomersD           string
+	InfoGain          string
+	Dot               string
+	EdgeList          string
+	EdgeStat          string
+	EdgeThreshold     float64
+	Bayes             string
+	PriorAlpha        float64
+	PriorBeta         float64
+	Credible          string
+	CredibleLevel     float64
+	Merges            string
+	Clusters          string
+	ClusterDistance   string
+	ClusterK          int
+	ClusterHeight     float64
+	Reorder           string
+	JointsMatrix      string
+	AntiCorrelated    string
+	AntiSupport       int
+	NearDuplicates    string
+	NearAgreement     float64
+	Percent           bool
+	Sci               bool
+	Precision         int
+	Pretty            bool
+	SQLite            string
+	RTable            bool
+	GraphML           string
+	Config            string
+	Manifest          string
+	Validate          bool
+	Estimate          bool
+	EstimateReads     int
+	Strict            bool
+	SkipBad           bool
+	AllErrors         bool
+	Pad               bool
+	LowercaseColumns  bool
+	Dedup             string
+	DedupAgg          string
+	DedupMin          int
+	Unique            bool
+	Multiplicity      bool
+	Inputs            string
+	SortCols          bool
+	Stable            bool
+	Header            bool
+	NaToken           string
+	MissingValue      string
+	MissingReport     string
+	LogFile           string
+	LogFileAppend     bool
+	MaxErrors         int
+	Summary           bool
+	Timings           bool
+	Verbose           bool
+	Quiet             bool
+	LogJSON           bool
+	Outdir            string
+	WriteMarginals    bool
+	WriteJoints       bool
+	WriteConditionals bool
+	Any               string
+	AnyOf             string
+	All               string
+	AllOf             string
+	GivenConditionals string
+	Given             string
+	Anomalies         string
+	AnomalyPercentile float64
+	LOO               string
+	Smoothing         float64
+	NBModel           string
+	Label             string
+	Predict           string
+	PredictInput      string
+	CVReport          string
+	CV                int
+	Seed              int64
+	Confusion         string
+	DecisionThreshold float64
+	ROC               string
+	AUC               string
+	PR                string
+	KL                string
+	JS                string
+	Hamming           string
+	HammingNormalize  bool
+	JointSE           bool
+	ConditionalCI     bool
+	MinSupport        int
+	Bidirectional     bool
+	SkipZeroJoints    bool
+	Self              bool
+	JointBlocks       int
+	JointColumns      string
+	MaxPairs          int64
+	Rules             string
+	MinConf           float64
+	RulesSort         string
+	TopRules          int
+	Itemsets          string
+	ClosedItemsets    string
+	MaxItemsetSize    int
+	CILevel           float64
+	Triples           string
+	Triangle          bool
+	Pairs             string
+	Input             string
+	Relaxed           bool
+	Binarize          bool
+	Threshold         float64
+	Thresholds        string
+	Onehot            string
+	WeightCol         string
+	WeightedMarginals string
+	Permutation       string
+	Permute           int
+	GroupBy           string
+	GroupDiff         string
+	ZTest             string
+	ZTestGroups       string
+	Correction        string
+	CPT               string
+	Target            string
+	Parents           string
+	MaxParents        int
+	Prevalence        string
+	CorrelationMatrix string
+	JaccardDistances  string
+	Phylip            string
+	PhylipDistance    string
+	ContingencyLong   string
+	MIRank            string
+	ChowLiu           string
+	ChowLiuEdges      string
+	ChowLiuDot        string
+	ChowLiuRoot       string
+	FlushInterval     time.Duration
+	FlushEvery        int
+	Mmap              bool
+	Threads           int
+	Benchmark         bool
+	BenchmarkReads    int
+	BenchmarkColumns  int
+	Generate          bool
+	Example           bool
+	GenerateReads     int
+	GenerateColumns   int
+	GenerateDensity   float64
+	GenerateCorrelate string
+	Checkpoint        string
+	CheckpointEvery   int
+	Resume            bool
+	Watch             bool
+	WatchInterval     time.Duration
 }
 
+// interruptCheckEvery is how many reads pass between checks for an
+// interruption.
+const interruptCheckEvery = 1024
+
+// random is the source of randomness for every randomized feature. It is
+// reseeded from -seed before each output is written, so that an output
+// doesn't depend on which other outputs were written before it.
+var random *rand.Rand
+
 var args = Args{}
 
 func init() {
 	log.SetFlags(0)
 	flag.StringVar(&args.Marginals, "marginals", "", "file to write marginal probabilities to")
+	flag.StringVar(&args.Prevalence, "prevalence", "", "file to write the columns to from the most to the least common")
+	flag.StringVar(&args.Any, "any", "", "file to write the probability that a read is 1 in any of the -any-of columns to")
+	flag.StringVar(&args.AnyOf, "any-of", "", "comma-separated columns for -any (default = all columns)")
+	flag.StringVar(&args.All, "all", "", "file to write the probability that a read is 1 in all of the -all-of columns to")
+	flag.StringVar(&args.AllOf, "all-of", "", "comma-separated columns for -all (default = all columns)")
+	flag.StringVar(&args.GivenConditionals, "given-conditionals", "", "file to write the probability of each other column given that a read is 1 in all of the -given columns to")
+	flag.StringVar(&args.Given, "given", "", "comma-separated columns to condition on for -given-conditionals")
 	flag.StringVar(&args.Joints, "joints", "", "file to write joint probabilities to")
+	flag.BoolVar(&args.JointSE, "joint-se", false, "add the binomial standard error of each joint probability to the joints output")
+	flag.BoolVar(&args.ConditionalCI, "conditional-ci", false, "add the Wilson interval of each conditional probability to the conditionals output")
+	flag.IntVar(&args.MinSupport, "minsupport", 0, "leave out conditionals P(A|B) where B is 1 in fewer than this many reads, and -rules A => B where A and B are 1 together in fewer")
+	flag.BoolVar(&args.Bidirectional, "bidirectional", false, "write the -conditionals of each unordered pair on one line, P(B|A) and P(A|B), instead of one line per direction")
+	flag.BoolVar(&args.SkipZeroJoints, "skip-zero-joints", false, "leave out the -joints and -conditionals of pairs that are never 1 together")
+	flag.BoolVar(&args.Self, "self", true, "write the -joints and -conditionals of each column with itself; -self=false leaves them out")
+	flag.IntVar(&args.JointBlocks, "joint-blocks", 0, "tally the -joints in blocks of this many columns, rereading the -input for each pair of blocks, instead of holding every joint count in memory")
+	flag.StringVar(&args.JointColumns, "joint-columns", "", "comma-separated columns to count the joints among, for the outputs that need them, while the marginals are still of every column (default = all columns)")
+	flag.Int64Var(&args.MaxPairs, "max-pairs", 100000000, "refuse to tally the joints of more than this many pairs of columns, the square of the number of columns (0 = no limit)")
+	flag.StringVar(&args.Rules, "rules", "", "file to write the association rules between pairs of columns to, with their support, confidence and lift")
+	flag.Float64Var(&args.MinConf, "minconf", 0, "minimum confidence P(B|A) of the -rules A => B")
+	flag.StringVar(&args.RulesSort, "rules-sort", "confidence", "order to list -rules in, strongest first: "+strings.Join(ruleSorts, ", "))
+	flag.IntVar(&args.TopRules, "top-rules", 0, "write only this many of the strongest -rules (default = 0 = all of them)")
+	flag.StringVar(&args.Itemsets, "itemsets", "", "file to write the sets of columns that are 1 together in at least -minsupport reads to")
+	flag.StringVar(&args.ClosedItemsets, "closed-itemsets", "", "file to write the -itemsets to that have no superset 1 in as many reads")
+	flag.IntVar(&args.MaxItemsetSize, "max-itemset-size", 3, "maximum number of columns in the -itemsets")
+	flag.Float64Var(&args.CILevel, "ci-level", 0.95, "probability covered by the -conditional-ci intervals")
+	flag.StringVar(&args.Triples, "triples", "", "file to write the non-zero joint counts to as 'column, column, count' triples")
+	flag.BoolVar(&args.Triangle, "triangle", false, "write each unordered pair only once in the joints, -triples and -correlation-matrix outputs")
+	flag.StringVar(&args.Pairs, "pairs", "", "two-column TSV of the only column pairs to compute joints and conditionals for")
+	flag.StringVar(&args.JointsMatrix, "joints-matrix", "", "file to write joint probabilities to as a labeled square matrix")
+	flag.BoolVar(&args.Header, "header", true, "write a header row and row labels in the matrix outputs; -header=false writes just the numbers")
 	flag.StringVar(&args.Conditionals, "conditionals", "", "file to write conditional probabilities to")
-	flag.IntVar(&args.Limit, "limit", 0, "limit the number of lines of stdin to consider (default = 0 = unlimited)")
+	flag.StringVar(&args.MCC, "mcc", "", "file to write the Matthews correlation coefficient of each pair to")
+	flag.BoolVar(&args.PhiMax, "phi-max", false, "add each pair's phi divided by the largest phi its marginals allow, phi/phi_max, to the -mcc output")
+	flag.StringVar(&args.JaccardDistances, "jaccard-distances", "", "file to write the 1 - Jaccard distance between each pair of columns to as a labeled square matrix")
+	flag.StringVar(&args.Phylip, "phylip", "", "file to write the distance between each pair of columns to as a PHYLIP distance matrix, with names cut or padded to 10 characters")
+	flag.StringVar(&args.PhylipDistance, "phylip-distance", "jaccard", "distance for -phylip: 1 - jaccard, or hamming as the fraction of reads the columns differ in")
+	flag.StringVar(&args.CorrelationMatrix, "correlation-matrix", "", "file to write the phi correlation of each pair to as a labeled square matrix")
+	flag.StringVar(&args.ContingencyLong, "contingency-long", "", "file to write the four contingency table cells of each pair to, one row per cell")
+	flag.StringVar(&args.Yule, "yule", "", "file to write Yule's Q and Y coefficients of each pair to")
+	flag.StringVar(&args.ChiSquare, "chisquare", "", "file to write the chi-square test of independence of each pair to")
+	flag.BoolVar(&args.Yates, "yates", false, "apply Yates' continuity correction to the -chisquare statistics")
+	flag.StringVar(&args.Tetrachoric, "tetrachoric", "", "file to write the tetrachoric correlation of each pair, estimated by maximum likelihood, to")
+	flag.StringVar(&args.Enrichment, "enrichment", "", "file to write the one-sided hypergeometric p-value of each pair's overlap, and the overlap observed and expected, to")
+	flag.IntVar(&args.TopEnriched, "top-enriched", 0, "write only this many of the most significant -enrichment pairs, in increasing order of p-value (default = 0 = all of them, in column order)")
+	flag.StringVar(&args.TheilU, "theil", "", "file to write Theil's uncertainty coefficient of each ordered pair to")
+	flag.StringVar(&args.Lambda, "lambda", "", "file to write the Goodman-Kruskal lambda of each ordered pair to")
+	flag.StringVar(&args.SomersD, "somers", "", "file to write Somers' D of each ordered pair to")
+	flag.StringVar(&args.MIRank, "mi-rank", "", "file to write every column to in order of its mutual information with the -target")
+	flag.StringVar(&args.ChowLiu, "chow-liu", "", "file to write the edges of the maximum mutual information spanning tree of the columns to")
+	flag.StringVar(&args.ChowLiuEdges, "chow-liu-edges", "", "file to write the -chow-liu tree to as an edge list directed from the -chow-liu-root")
+	flag.StringVar(&args.ChowLiuDot, "chow-liu-dot", "", "file to write the -chow-liu tree to as a Graphviz graph directed from the -chow-liu-root")
+	flag.StringVar(&args.ChowLiuRoot, "chow-liu-root", "", "column the Chow-Liu tree is directed away from (default = the first column)")
+	flag.StringVar(&args.KL, "kl", "", "file to write the Kullback-Leibler divergence between each ordered pair of columns' distributions to")
+	flag.StringVar(&args.JS, "js", "", "file to write the Jensen-Shannon divergence between each pair of columns' distributions to")
+	flag.StringVar(&args.InfoGain, "infogain", "", "file to write the information gain about each column from each other column to")
+	flag.StringVar(&args.Dot, "dot", "", "file to write a Graphviz graph of the associated pairs to")
+	flag.StringVar(&args.EdgeList, "edges", "", "file to write an edge list of the associated pairs to")
+	flag.StringVar(&args.GraphML, "graphml", "", "file to write a GraphML graph of the associated pairs to")
+	flag.StringVar(&args.EdgeStat, "edge-stat", "correlation", "statistic linking pairs in graph outputs: "+strings.Join(edgeStats, ", "))
+	flag.Float64Var(&args.EdgeThreshold, "edge-threshold", 0, "only link pairs in graph outputs whose statistic exceeds this")
+	flag.StringVar(&args.Bayes, "bayes", "", "file to write the Beta posterior mean and variance of each marginal to")
+	flag.Float64Var(&args.PriorAlpha, "prior-alpha", 0.5, "alpha of the Beta prior used by -bayes and -credible (0.5 is the Jeffreys prior)")
+	flag.Float64Var(&args.PriorBeta, "prior-beta", 0.5, "beta of the Beta prior used by -bayes and -credible (0.5 is the Jeffreys prior)")
+	flag.StringVar(&args.Credible, "credible", "", "file to write Beta posterior credible intervals of each marginal to")
+	flag.Float64Var(&args.CredibleLevel, "credible-level", 0.95, "probability mass covered by the -credible intervals")
+	flag.StringVar(&args.Merges, "merges", "", "file to write the merge order of the hierarchical clustering of columns to")
+	flag.StringVar(&args.Clusters, "clusters", "", "file to write the cluster assignment of each column to")
+	flag.StringVar(&args.ClusterDistance, "cluster-distance", "jaccard", "distance to cluster columns by: 1 - jaccard or 1 - |correlation|")
+	flag.IntVar(&args.ClusterK, "cluster-k", 0, "cut the clustering into this many clusters (default = 0 = cut by -cluster-height)")
+	flag.Float64Var(&args.ClusterHeight, "cluster-height", 0.5, "cut the clustering at this distance when -cluster-k isn't given")
+	flag.StringVar(&args.Reorder, "reorder", "", "order columns by the clustering leaf order in every output, writing the permutation to this file")
+	flag.BoolVar(&args.SortCols, "sortcols", false, "order columns from the most to the least common in every output")
+	flag.BoolVar(&args.Stable, "stable", false, "order columns by name in every output, so that reordering the input's columns doesn't change them")
+	flag.StringVar(&args.AntiCorrelated, "anticorrelated", "", "file to write negatively correlated pairs to, most negative first")
+	flag.IntVar(&args.AntiSupport, "anti-support", 1, "minimum count of each column of a pair reported by -anticorrelated")
+	flag.StringVar(&args.Hamming, "hamming", "", "file to write the Hamming distance between each pair of columns to")
+	flag.BoolVar(&args.HammingNormalize, "hamming-normalize", false, "divide -hamming distances by the number of reads")
+	flag.StringVar(&args.NearDuplicates, "near-duplicates", "", "file to write pairs of nearly identical columns to")
+	flag.Float64Var(&args.NearAgreement, "near-agreement", 0.95, "minimum fraction of reads on which -near-duplicates columns agree")
+	flag.BoolVar(&args.Percent, "percent", false, "write marginal, joint and conditional probabilities as percentages")
+	flag.BoolVar(&args.Sci, "sci", false, "write marginal, joint and conditional probabilities in scientific notation")
+	flag.StringVar(&args.NaToken, "na-token", "NaN", "token written for undefined values in every output except -rtable and -sqlite, which use NA and NULL")
+	flag.IntVar(&args.Precision, "precision", 0, "decimal places, or significant digits with -sci, of probabilities (default = 0 = 6 for marginals, 8 otherwise)")
+	flag.BoolVar(&args.Pretty, "pretty", false, "write marginals, joints and conditionals as aligned tables for reading in a terminal")
+	flag.StringVar(&args.SQLite, "sqlite", "", "file to write a SQLite database of the marginals, joints, conditionals and input values to")
+	flag.BoolVar(&args.RTable, "rtable", false, "write marginals, joints and conditionals as tables for R's read.table(header=TRUE)")
+	flag.StringVar(&args.Config, "config", "", "YAML file of 'option: value' lines setting any of these options; command line flags take precedence")
+	flag.StringVar(&args.Manifest, "manifest", "", "file to write a JSON description of a successful run to: inputs with their hashes, flags, matrix size and outputs")
+	flag.BoolVar(&args.Validate, "validate", false, "check that the whole input is well-formed and report its size without computing anything")
+	flag.BoolVar(&args.Estimate, "estimate", false, "print the memory, output lines and rough time a run would take, from the header and the first -estimate-reads reads, without computing anything")
+	flag.IntVar(&args.EstimateReads, "estimate-reads", 1000, "number of reads -estimate times to project the time of a run (0 = read only the header)")
+	flag.BoolVar(&args.Strict, "strict", true, "stop at the first invalid line of wide format input; with -strict=false skip invalid lines and report them all at the end")
+	flag.IntVar(&args.MaxErrors, "max-errors", 100, "maximum number of invalid lines reported with -strict=false")
+	flag.BoolVar(&args.SkipBad, "skip-bad", false, "skip invalid lines of wide format input, only warning how many were skipped")
+	flag.BoolVar(&args.AllErrors, "all-errors", false, "read on past invalid lines of wide format input to report every invalid cell, up to -max-errors, before exiting without writing any outputs")
+	flag.BoolVar(&args.Pad, "pad", false, "fill out lines of wide format input with fewer fields than the header with zeros")
+	flag.StringVar(&args.MissingValue, "missing-value", "", "cell value of wide format input marking a missing value, which is counted and tallied as 0")
+	flag.StringVar(&args.MissingReport, "missing-report", "", "file to write the number of -missing-value and observed cells of each column to")
+	flag.BoolVar(&args.Timings, "timings", false, "print the time spent parsing, accumulating counts and writing outputs to stderr")
+	flag.BoolVar(&args.Summary, "summary", false, "print the number of reads and columns, density and extreme marginals to stderr")
+	flag.BoolVar(&args.Verbose, "v", false, "verbose logging, including timings and row counts")
+	flag.BoolVar(&args.Quiet, "q", false, "quiet logging of errors only")
+	flag.BoolVar(&args.LogJSON, "log-json", false, "write log messages to stderr as JSON objects")
+	flag.StringVar(&args.LogFile, "logfile", "", "file to write diagnostics to instead of stderr; fatal errors are written to stderr too")
+	flag.BoolVar(&args.LogFileAppend, "logfile-append", false, "append to the -logfile rather than truncating it")
+	flag.StringVar(&args.Outdir, "outdir", "", "directory to write the outputs enabled by -write-marginals, -write-joints and -write-conditionals to")
+	flag.BoolVar(&args.WriteMarginals, "write-marginals", false, "write marginals to marginals.tsv in -outdir")
+	flag.BoolVar(&args.WriteJoints, "write-joints", false, "write joints to joints.tsv in -outdir")
+	flag.BoolVar(&args.WriteConditionals, "write-conditionals", false, "write conditionals to conditionals.tsv in -outdir")
+	flag.StringVar(&args.Anomalies, "anomalies", "", "file to write the reads that are least likely under independence to")
+	flag.Float64Var(&args.AnomalyPercentile, "anomaly-percentile", 1, "percentage of reads with the lowest log-likelihood reported by -anomalies")
+	flag.StringVar(&args.LOO, "loo", "", "file to write leave-one-out naive Bayes predictions of every cell to")
+	flag.Float64Var(&args.Smoothing, "smoothing", 1, "pseudo-count added to each outcome when estimating naive Bayes and -cpt probabilities")
+	flag.StringVar(&args.NBModel, "nb-model", "", "file to write a naive Bayes model predicting the -label column to")
+	flag.StringVar(&args.CPT, "cpt", "", "file to write the conditional probability table of the -target column given the -parents to")
+	flag.StringVar(&args.Target, "target", "", "column whose probability the -cpt table gives, and that -mi-rank ranks columns against")
+	flag.StringVar(&args.Parents, "parents", "", "comma-separated columns the -cpt table conditions on")
+	flag.IntVar(&args.MaxParents, "max-parents", 12, "maximum number of -parents, since the -cpt table has a row for each combination of their values")
+	flag.StringVar(&args.Label, "label", "", "0/1 column treated as the class label by naive Bayes")
+	flag.StringVar(&args.Predict, "predict", "", "file to write the naive Bayes posterior of each read's -label class to")
+	flag.StringVar(&args.PredictInput, "predict-input", "", "wide format matrix of reads to score with -predict instead of the training reads")
+	flag.StringVar(&args.CVReport, "cv-report", "", "file to write the metrics of -cv fold cross-validation of naive Bayes to")
+	flag.IntVar(&args.CV, "cv", 5, "number of folds for -cv-report")
+	flag.StringVar(&args.Confusion, "confusion", "", "file to write the confusion matrix of the naive Bayes predictions against the actual -label to")
+	flag.StringVar(&args.ROC, "roc", "", "file to write the ROC curve of the naive Bayes predictions of the -label to")
+	flag.StringVar(&args.AUC, "auc", "", "file to write the area under the ROC curve of the naive Bayes predictions to")
+	flag.StringVar(&args.PR, "pr", "", "file to write the precision-recall curve and average precision of the naive Bayes predictions to")
+	flag.Float64Var(&args.DecisionThreshold, "decision-threshold", 0.5, "posterior probability above which naive Bayes predicts class 1")
+	flag.Int64Var(&args.Seed, "seed", 1, "seed for the random number generator")
+	flag.BoolVar(&args.Relaxed, "relaxed", false, "also accept values written as other numbers equal to 0 or 1, such as 0.0 and 1.0")
+	flag.BoolVar(&args.Binarize, "binarize", false, "accept any non-negative number as a value, taking every positive one to be 1, e.g. for read counts; -threshold sets other cutoffs")
+	flag.Float64Var(&args.Threshold, "threshold", 0, "accept any number as a value, taking those at least this to be 1, e.g. for continuous features")
+	flag.StringVar(&args.Thresholds, "thresholds", "", "two-column TSV of per-column -threshold cutoffs, which override -threshold")
+	flag.StringVar(&args.Onehot, "onehot", "", "categorical column to replace with a 'column=value' indicator column for each of its values")
+	flag.StringVar(&args.WeightCol, "weightcol", "", "numeric column giving the weight of each read, rather than an indicator; only the -weighted-marginals and -summary use the weights")
+	flag.StringVar(&args.WeightedMarginals, "weighted-marginals", "", "file to write the -weightcol weighted marginal probabilities and their standard errors to")
+	flag.StringVar(&args.Permutation, "permutation", "", "file to write a permutation test of the association of each pair of columns, or of the -pairs, to")
+	flag.IntVar(&args.Permute, "permute", 1000, "number of permutations for -permutation")
+	flag.StringVar(&args.GroupBy, "groupby", "", "column naming the group of each read, rather than an indicator")
+	flag.StringVar(&args.GroupDiff, "group-diff", "", "file to write the difference of each column's marginal between the two -groupby groups to")
+	flag.StringVar(&args.ZTest, "ztest", "", "file to write a two-sample z-test of each column's marginal between two -groupby groups to")
+	flag.StringVar(&args.ZTestGroups, "ztest-groups", "", "the two -groupby groups for -ztest, separated by a comma (default the only two)")
+	flag.StringVar(&args.Correction, "correction", "none", "multiple-testing correction of the p-values across the columns, or the pairs of -enrichment: none, bonferroni, bh")
+	flag.StringVar(&args.Input, "input", "", "file or named pipe to read the input from (default = stdin)")
+	flag.DurationVar(&args.FlushInterval, "flush-interval", 0, "rewrite every output with the counts so far at this interval while reading, e.g. 30s")
+	flag.IntVar(&args.FlushEvery, "flush-every", 0, "rewrite the -marginals, and the -joints if selected, with the counts so far every this many reads (default = 0 = only at the end)")
+	flag.IntVar(&args.Threads, "threads", 1, "number of goroutines parsing wide format input")
+	flag.BoolVar(&args.Benchmark, "benchmark", false, "time parsing and tallying the joints of the input and print the throughput instead of computing any outputs")
+	flag.IntVar(&args.BenchmarkReads, "benchmark-reads", 0, "number of reads of random input for -benchmark to generate instead of reading the input (default = 0 = read the input)")
+	flag.IntVar(&args.BenchmarkColumns, "benchmark-columns", 100, "number of columns of the input generated by -benchmark-reads")
+	flag.BoolVar(&args.Generate, "generate", false, "write a random matrix of indicators, seeded by -seed, to stdout instead of computing any outputs")
+	flag.IntVar(&args.GenerateReads, "generate-reads", 1000, "number of reads -generate writes")
+	flag.IntVar(&args.GenerateColumns, "generate-columns", 10, "number of columns, named c0, c1, ..., -generate writes")
+	flag.Float64Var(&args.GenerateDensity, "generate-density", 0.5, "probability that each value -generate writes is 1")
+	flag.StringVar(&args.GenerateCorrelate, "generate-correlate", "", "comma-separated 'source:target=strength' columns for -generate to correlate, the target copying the source, or its complement for a negative strength, in that fraction of reads")
+	flag.BoolVar(&args.Example, "example", false, "write a small example of the input format to stdout, to copy as a template, instead of computing any outputs")
+	flag.BoolVar(&args.Mmap, "mmap", false, "memory-map the -input file instead of reading it, when it is a regular file")
+	flag.StringVar(&args.Checkpoint, "checkpoint", "", "file to periodically save the counts so far to, so that the run can be resumed")
+	flag.IntVar(&args.CheckpointEvery, "checkpoint-every", 1000000, "number of reads between -checkpoint saves")
+	flag.BoolVar(&args.Resume, "resume", false, "restore the counts from the -checkpoint file and continue after the reads it covers")
+	flag.BoolVar(&args.Watch, "watch", false, "keep running and recompute every output whenever the -input file changes")
+	flag.DurationVar(&args.WatchInterval, "watch-interval", time.Second, "how often -watch checks the -input file for changes")
+	flag.BoolVar(&args.Long, "long", false, "read long format input with one read, variable, value observation per line")
+	flag.StringVar(&args.Sparse, "sparse", "", "read positives-only 'read, column' input, taking the full column set from the named file")
+	flag.StringVar(&args.Aliases, "aliases", "", "two-column TSV mapping column names to the names to display in the output")
+	flag.BoolVar(&args.LowercaseColumns, "lowercase-columns", false, "lowercase every column name as the input is read, so that names differing only in case are duplicates")
+	flag.StringVar(&args.Dedup, "dedup", "", "keep only the first or last occurrence of each read name: first, last")
+	flag.StringVar(&args.DedupAgg, "dedup-agg", "", "combine the occurrences of each read name, holding every read in memory: or, and, sum")
+	flag.IntVar(&args.DedupMin, "dedup-min", 1, "number of occurrences of a read that must be 1 for -dedup-agg sum to give 1")
+	flag.StringVar(&args.Inputs, "inputs", "", "comma-separated files or named pipes with the same header to read all at once as one input, instead of the -input; -limit applies to each")
+	flag.BoolVar(&args.Multiplicity, "multiplicity", false, "collapse each distinct row, of the same read name and values, into one read counted by the number of times it occurs, holding every row in memory")
+	flag.BoolVar(&args.Unique, "unique", false, "keep only the first occurrence of each distinct row, of the same read name and values, dropping the repeats")
+	flag.IntVar(&args.Limit, "limit", 0, "limit the number of lines of input to consider (default = 0 = unlimited)")
 
 	flag.Usage = func() {
 		log.Println("usage: matrixprobs [options] < matrix.tsv")
@@ -37,139 +393,776 @@ func init() {
 	}
 }
 
-func main() {
-	flag.Parse()
+// setOutdirPaths points the outputs enabled by the -write flags at their
+// default file names in -outdir, unless they were given explicitly.
+func setOutdirPaths() {
+	defaults := []struct {
+		enabled bool
+		path    *string
+		name    string
+	}{
+		{args.WriteMarginals, &args.Marginals, "marginals.tsv"},
+		{args.WriteJoints, &args.Joints, "joints.tsv"},
+		{args.WriteConditionals, &args.Conditionals, "conditionals.tsv"},
+	}
+	for _, d := range defaults {
+		if d.enabled && *d.path == "" {
+			*d.path = filepath.Join(args.Outdir, d.name)
+		}
+	}
+}
+
+// checkArgs exits with an error message if the options are invalid.
+func checkArgs() {
+	if args.Outdir == "" && (args.WriteMarginals || args.WriteJoints || args.WriteConditionals) {
+		fatal("-write-marginals, -write-joints and -write-conditionals require -outdir")
+	}
 
-	if args.Marginals == "" && args.Joints == "" && args.Conditionals == "" {
-		log.Println("Must specify at least one of -marginals, -joints, and/or -conditionals")
+	if len(selectedOutputs()) == 0 && !args.Validate && !args.Estimate && !args.Benchmark && !args.Generate && !args.Example {
+		errorf("Must specify at least one output, such as -marginals, -joints, and/or -conditionals")
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	var fieldNames []string
-	var row []int
-	var marginals []int
-	var joints [][]int
-	calcJoints := args.Joints != "" || args.Conditionals != ""
-	numFields := 0
+	if !validEdgeStat(args.EdgeStat) {
+		fatalf("-edge-stat must be one of %s\n", strings.Join(edgeStats, ", "))
+	}
+
+	if args.PriorAlpha <= 0 || args.PriorBeta <= 0 {
+		fatal("-prior-alpha and -prior-beta must be positive")
+	}
+
+	if args.CredibleLevel <= 0 || args.CredibleLevel >= 1 {
+		fatal("-credible-level must be between 0 and 1")
+	}
+
+	if (args.GivenConditionals != "") != (args.Given != "") {
+		fatal("-given-conditionals and -given must be used together")
+	}
+
+	if !validPhylipDistance(args.PhylipDistance) {
+		fatalf("-phylip-distance must be one of %s\n", strings.Join(phylipDistances, ", "))
+	}
+
+	if !validClusterDistance(args.ClusterDistance) {
+		fatalf("-cluster-distance must be one of %s\n", strings.Join(clusterDistances, ", "))
+	}
+
+	if args.NearAgreement < 0 || args.NearAgreement > 1 {
+		fatal("-near-agreement must be between 0 and 1")
+	}
+
+	if args.AnomalyPercentile < 0 || args.AnomalyPercentile > 100 {
+		fatal("-anomaly-percentile must be between 0 and 100")
+	}
+
+	if args.DecisionThreshold < 0 || args.DecisionThreshold > 1 {
+		fatal("-decision-threshold must be between 0 and 1")
+	}
+
+	if args.FlushInterval < 0 {
+		fatal("-flush-interval must not be negative")
+	}
+
+	if args.FlushInterval > 0 && args.Long {
+		fatal("-flush-interval can't be used with -long, which reads all its input up front")
+	}
+
+	if args.FlushInterval > 0 && (args.Reorder != "" || args.SortCols || args.Stable) {
+		fatal("-flush-interval can't be used with -reorder, -sortcols or -stable")
+	}
+
+	if args.FlushEvery < 0 {
+		fatal("-flush-every must not be negative")
+	}
+
+	if args.FlushEvery > 0 && (args.FlushInterval > 0 || args.Long || args.Onehot != "" || args.Reorder != "" || args.SortCols || args.Stable) {
+		fatal("-flush-every can't be used with -flush-interval, -long, -onehot, -reorder, -sortcols or -stable")
+	}
+
+	if args.BenchmarkReads < 0 || args.BenchmarkColumns < 1 {
+		fatal("-benchmark-reads must not be negative and -benchmark-columns must be at least 1")
+	}
+
+	if args.EstimateReads < 0 {
+		fatal("-estimate-reads must not be negative")
+	}
+
+	if args.GenerateReads < 0 || args.GenerateColumns < 1 {
+		fatal("-generate-reads must not be negative and -generate-columns must be at least 1")
+	}
+
+	if args.GenerateDensity < 0 || args.GenerateDensity > 1 {
+		fatal("-generate-density must be from 0 to 1")
+	}
+
+	if args.Binarize && (flagGiven("threshold") || args.Thresholds != "") {
+		fatal("-binarize can't be used with -threshold or -thresholds; -threshold sets a cutoff other than 0")
+	}
+
+	if args.Onehot != "" && (args.Long || args.Sparse != "") {
+		fatal("-onehot only applies to wide format input")
+	}
+
+	if args.Onehot != "" && args.FlushInterval > 0 {
+		fatal("-flush-interval can't be used with -onehot, which reads all its input up front")
+	}
+
+	if (args.WeightCol != "" || args.GroupBy != "") && (args.Long || args.Sparse != "" || args.Onehot != "") {
+		fatal("-weightcol and -groupby only apply to plain wide format input")
+	}
+
+	if (args.WeightCol != "" || args.GroupBy != "") && args.Checkpoint != "" {
+		fatal("-checkpoint doesn't save -weightcol weights or -groupby groups")
+	}
+
+	if (args.GroupDiff != "" || args.ZTest != "") && args.GroupBy == "" {
+		fatal("-group-diff and -ztest require a -groupby column")
+	}
+
+	if args.ZTestGroups != "" && len(strings.Split(args.ZTestGroups, ",")) != 2 {
+		fatal("-ztest-groups must name two groups separated by a comma")
+	}
+
+	if !validCorrection(args.Correction) {
+		fatalf("-correction must be one of: %s\n", strings.Join(corrections, ", "))
+	}
+
+	if args.WeightedMarginals != "" && args.WeightCol == "" {
+		fatal("-weighted-marginals requires a -weightcol")
+	}
+
+	if args.WeightCol != "" {
+		var unweighted []string
+		for _, out := range selectedOutputs() {
+			if out.path != &args.WeightedMarginals {
+				unweighted = append(unweighted, out.name)
+			}
+		}
+		if unweighted != nil {
+			warnf("-weightcol only weights the -weighted-marginals, so these outputs count every read once: %s\n", strings.Join(unweighted, ", "))
+		}
+	}
+
+	if args.Threads < 1 {
+		fatal("-threads must be at least 1")
+	}
+
+	if args.CheckpointEvery < 1 {
+		fatal("-checkpoint-every must be at least 1")
+	}
+
+	if args.Resume && args.Checkpoint == "" {
+		fatal("-resume requires a -checkpoint file")
+	}
+
+	if args.Watch && args.Input == "" {
+		fatal("-watch requires an -input file")
+	}
+
+	if args.WatchInterval <= 0 {
+		fatal("-watch-interval must be positive")
+	}
+
+	if !args.Strict && args.SkipBad {
+		fatal("-strict=false and -skip-bad can't be used together")
+	}
+
+	if args.AllErrors && (!args.Strict || args.SkipBad) {
+		fatal("-all-errors only applies in -strict mode and can't be used with -skip-bad")
+	}
+
+	if args.MissingReport != "" && args.MissingValue == "" {
+		fatal("-missing-report requires a -missing-value")
+	}
+
+	if args.MissingReport != "" && (args.Dedup != "" || args.DedupAgg != "" || args.Unique || args.Multiplicity || args.Checkpoint != "") {
+		fatal("-missing-report counts every line of input, so it can't be used with -dedup, -dedup-agg, -unique, -multiplicity or -checkpoint")
+	}
+
+	if (!args.Strict || args.SkipBad || args.AllErrors || args.Pad || args.MissingValue != "") && (args.Long || args.Sparse != "" || args.Onehot != "") {
+		fatal("-strict=false, -skip-bad, -all-errors, -pad and -missing-value only apply to wide format input")
+	}
+
+	if args.Inputs != "" && (args.Input != "" || args.Validate || args.Estimate || args.Benchmark || args.Watch || args.JointBlocks > 0 ||
+		args.Checkpoint != "" || args.Manifest != "") {
+		fatal("-inputs can't be used with -input, -validate, -estimate, -benchmark, -watch, -joint-blocks, -checkpoint or -manifest")
+	}
+
+	if args.TopEnriched < 0 {
+		fatal("-top-enriched must not be negative")
+	}
+
+	if args.MaxItemsetSize < 1 {
+		fatal("-max-itemset-size must be at least 1")
+	}
+
+	if args.JointBlocks < 0 {
+		fatal("-joint-blocks must not be negative")
+	}
+
+	if args.JointBlocks > 0 && (args.Input == "" || args.Watch || args.FlushInterval > 0 || args.FlushEvery > 0 || args.Pretty || args.RTable || args.Pairs != "" || args.WeightCol != "" ||
+		args.Dedup != "" || args.DedupAgg != "" || args.Unique || args.Multiplicity || args.Resume) {
+		fatal("-joint-blocks needs an -input file to reread, in the plain format, and can't be used with -watch, -flush-interval, -flush-every, -pairs, -weightcol, -dedup, -dedup-agg, -unique, -multiplicity or -resume")
+	}
+
+	if args.JointColumns != "" && (args.Pairs != "" || args.JointBlocks > 0) {
+		fatal("-joint-columns can't be used with -pairs or -joint-blocks")
+	}
+
+	if args.Bidirectional && (args.Pretty || args.RTable || args.ConditionalCI) {
+		fatal("-bidirectional can't be used with -pretty, -rtable or -conditional-ci")
+	}
+
+	if args.RulesSort != "confidence" && args.RulesSort != "lift" {
+		fatalf("-rules-sort must be one of: %s\n", strings.Join(ruleSorts, ", "))
+	}
+
+	if (args.SortCols && args.Stable) || (args.Reorder != "" && (args.SortCols || args.Stable)) {
+		fatal("only one of -sortcols, -stable and -reorder can be used")
+	}
+
+	if args.Dedup != "" && args.Dedup != "first" && args.Dedup != "last" {
+		fatal("-dedup must be first or last")
+	}
+
+	if args.DedupAgg != "" && args.DedupAgg != "or" && args.DedupAgg != "and" && args.DedupAgg != "sum" {
+		fatal("-dedup-agg must be or, and, or sum")
+	}
+
+	dedups := 0
+	for _, given := range []bool{args.Dedup != "", args.DedupAgg != "", args.Unique, args.Multiplicity} {
+		if given {
+			dedups++
+		}
+	}
+	if dedups > 1 {
+		fatal("only one of -dedup, -dedup-agg, -unique and -multiplicity can be used")
+	}
+
+	if args.Multiplicity && (args.Checkpoint != "" || args.FlushEvery > 0) {
+		fatal("-multiplicity counts reads in bulk, so it can't be used with -checkpoint or -flush-every")
+	}
+
+	if args.DedupMin < 1 {
+		fatal("-dedup-min must be at least 1")
+	}
+
+	if args.Manifest != "" && args.Watch {
+		fatal("-manifest describes a single run and can't be used with -watch")
+	}
+
+	if args.Permute < 1 {
+		fatal("-permute must be at least 1")
+	}
+
+	if args.CV < 2 {
+		fatal("-cv must be at least 2")
+	}
+
+	if (args.CPT != "" || args.MIRank != "") && (args.Target == "" || strings.Contains(args.Target, ",")) {
+		fatal("-cpt and -mi-rank require a single -target column")
+	}
+
+	if args.Parents != "" && len(strings.Split(args.Parents, ",")) > args.MaxParents {
+		fatalf("-parents lists more than the -max-parents of %d columns\n", args.MaxParents)
+	}
+
+	if args.CILevel <= 0 || args.CILevel >= 1 {
+		fatal("-ci-level must be between 0 and 1")
+	}
+
+	if args.Smoothing < 0 {
+		fatal("-smoothing must not be negative")
+	}
+
+	if args.Precision < 0 {
+		fatal("-precision must not be negative")
+	}
+
+	if args.Verbose && args.Quiet {
+		fatal("-v and -q are mutually exclusive")
+	}
+
+	if args.Pretty && args.RTable {
+		fatal("-pretty and -rtable are mutually exclusive")
+	}
+
+	if args.Long && args.Sparse != "" {
+		fatal("-long and -sparse are mutually exclusive")
+	}
+}
+
+func main() {
+	flag.Parse()
+
+	if args.Config != "" {
+		config, err := readConfig(args.Config)
+		if err != nil {
+			fatal(err)
+		}
+		if err := applyConfig(config); err != nil {
+			fatal(err)
+		}
+	}
+
+	if args.Outdir != "" {
+		setOutdirPaths()
+	}
 
-	var marFp *os.File
-	var jointFp *os.File
-	var condFp *os.File
+	if args.LogFile != "" {
+		if err := openLogFile(args.LogFile, args.LogFileAppend); err != nil {
+			fatal(err)
+		}
+	}
+
+	checkArgs()
+	random = rand.New(rand.NewSource(args.Seed))
+
+	if args.Validate {
+		validate()
+		return
+	}
+
+	if args.Estimate {
+		estimate()
+		return
+	}
+
+	if args.Benchmark {
+		benchmark()
+		return
+	}
+
+	if args.Generate {
+		generate()
+		return
+	}
+
+	if args.Example {
+		writeExample()
+		return
+	}
+
+	if args.Outdir != "" {
+		if err := os.MkdirAll(args.Outdir, 0755); err != nil {
+			fatalf("failed to create output directory '%s': %v\n", args.Outdir, err)
+		}
+	}
+
+	ctx := interruptContext()
 
 	// Get the output descriptors ready now so we fail early
-	if args.Marginals != "" {
+	req, err := openOutputs()
+	if err != nil {
+		fatal(err)
+	}
+
+	if flagGiven("threshold") {
+		defaultThreshold = args.Threshold
+	}
+	if args.Thresholds != "" {
 		var err error
-		marFp, err = os.Create(args.Marginals)
+		columnThresholds, err = readThresholds(args.Thresholds)
 		if err != nil {
-			log.Fatalf("failed to open marginals file '%s': %v\n", args.Marginals, err)
+			fatal(err)
 		}
 	}
-	if args.Joints != "" {
+
+	var aliases map[string]string
+	if args.Aliases != "" {
 		var err error
-		jointFp, err = os.Create(args.Joints)
+		aliases, err = readAliases(args.Aliases)
 		if err != nil {
-			log.Fatalf("failed to open joints file '%s': %v\n", args.Joints, err)
+			fatal(err)
 		}
 	}
-	if args.Conditionals != "" {
+
+	if args.Watch {
+		watchInput(ctx, req, aliases)
+		return
+	}
+
+	tally, err := tallyInput(ctx, req, aliases)
+	if errors.Is(err, errInterrupted) {
+		removeOutputs()
+		fatalf("%v; no outputs were written\n", err)
+	} else if err != nil {
+		fatal(err)
+	}
+	names := displayNames(tally.FieldNames, aliases)
+
+	if err := writeOutputs(ctx, tally, names); err != nil {
+		fatal(err)
+	}
+	if args.Summary {
+		writeSummary(os.Stderr, tally, names)
+	}
+	if args.Timings {
+		writeTimings(os.Stderr)
+	}
+	if badRows.report() > 0 {
+		// The outputs are written, but the input wasn't entirely valid
+		os.Exit(1)
+	}
+	if args.Manifest != "" {
+		if err := writeManifest(args.Manifest, tally); err != nil {
+			fatalf("failed to write manifest '%s': %v\n", args.Manifest, err)
+		}
+	}
+}
+
+// flagGiven reports whether a flag was set on the command line or by the
+// -config file.
+func flagGiven(name string) bool {
+	given := false
+	flag.Visit(func(f *flag.Flag) {
+		given = given || f.Name == name
+	})
+	return given
+}
+
+// openOutputs creates the file of each selected output, and works out what
+// they require to be tallied between them.
+func openOutputs() (requirements, error) {
+	var req requirements
+	for _, out := range selectedOutputs() {
 		var err error
-		condFp, err = os.Create(args.Conditionals)
+		out.fp, err = os.Create(*out.path)
 		if err != nil {
-			log.Fatalf("failed to open conditionals file '%s': %v\n", args.Conditionals, err)
+			return req, fmt.Errorf("failed to open %s file '%s': %v", out.name, *out.path, err)
+		}
+		req.joints = req.joints || out.joints
+		req.columns = req.columns || out.columns
+		req.anySet = req.anySet || out.anySet
+		req.allSet = req.allSet || out.allSet
+		req.given = req.given || out.given
+		req.label = req.label || out.label
+		req.target = req.target || out.target
+		if args.Pairs != "" && out.joints && !out.pairs {
+			return req, fmt.Errorf("the %s output needs every pair of columns and can't be used with -pairs", out.name)
+		}
+		if args.JointColumns != "" && out.joints && out.label {
+			return req, fmt.Errorf("the %s output needs the joints of every column with the -label and can't be used with -joint-columns", out.name)
 		}
+		if args.JointBlocks > 0 && out.joints && out.path != &args.Joints {
+			return req, fmt.Errorf("the %s output needs every joint count at once and can't be used with -joint-blocks", out.name)
+		}
+	}
+	if args.JointBlocks > 0 {
+		// The joints are tallied block by block as they are written
+		req.joints = false
+	}
+	if req.columns && args.Checkpoint != "" {
+		return req, errors.New("-checkpoint only saves counts, so it can't be used with outputs that need every read's values")
+	}
+	if req.columns && args.Multiplicity {
+		return req, errors.New("-multiplicity collapses the repeated rows, so it can't be used with outputs that need every read's values")
+	}
+	if req.label && args.Label == "" {
+		return req, errors.New("-label is required to train naive Bayes")
 	}
+	return req, nil
+}
 
-	scanner := bufio.NewScanner(os.Stdin)
-	lineNum := 0
-	numReads := 0
-	for scanner.Scan() {
-		if args.Limit > 0 && lineNum > args.Limit {
-			break
+// writeOutputs writes every selected output from the tally. If the context
+// is cancelled part way, the outputs are removed and the error wraps
+// errInterrupted.
+func writeOutputs(ctx context.Context, tally *Tally, names []string) error {
+	if tally.Groups != nil {
+		if err := checkGroups(tally); err != nil {
+			return err
 		}
-		line := scanner.Text()
-		lineNum++
-		fields := strings.Split(line, "\t")
-		if lineNum == 1 {
-			// This should be the header line
-			if len(fields) < 2 {
-				log.Fatalln("too few fields")
-			}
-			if fields[0] != "read" {
-				log.Fatalln("first field should be named 'read'")
-			}
-			fieldNames = fields[1:]
-			numFields = len(fieldNames)
-			log.Println("number of fields:", numFields)
-			// Allocate data structures we'll need
-			row = make([]int, numFields)
-			marginals = make([]int, numFields)
-			joints = make([][]int, numFields)
-			for i := 0; i < numFields; i++ {
-				joints[i] = make([]int, numFields)
+	}
+	writeStart := time.Now()
+	defer func() { timings.write += time.Since(writeStart) }()
+	if args.FlushInterval > 0 {
+		// The last snapshot is written the same way as the others
+		return writeSnapshot(tally, names)
+	}
+	for _, out := range selectedOutputs() {
+		if ctx.Err() != nil {
+			removeOutputs()
+			return fmt.Errorf("%w while writing the outputs; they were removed", errInterrupted)
+		}
+		start := time.Now()
+		if out.flushedEvery() {
+			// Earlier flushes replaced the file that was opened
+			if err := replaceOutput(out, tally, names); err != nil {
+				return err
 			}
 		} else {
-			// This should be a line naming the read and giving the values of the indicator variables
-			if len(fields) != numFields+1 {
-				log.Fatalf("expected line %d to have %d fields\n", lineNum, numFields+1)
+			out.writeSeeded(out.fp, tally, names)
+		}
+		verbosew(logFields{"output": out.name, "path": *out.path, "seconds": time.Since(start).Seconds()},
+			"wrote %s to '%s' in %v\n", out.name, *out.path, since(start))
+	}
+	return nil
+}
+
+// checkMaxPairs fails before any data is read if the joints of the columns
+// would take more than -max-pairs counts to hold.
+func checkMaxPairs(numFields int, joints bool) error {
+	if !joints || args.MaxPairs <= 0 {
+		return nil
+	}
+	numPairs := int64(numFields) * int64(numFields)
+	if numPairs > args.MaxPairs {
+		return fmt.Errorf("the %d columns make %d pairs, more than -max-pairs %d; raise -max-pairs "+
+			"if there is the memory for them, or use -joint-blocks for the -joints", numFields, numPairs, args.MaxPairs)
+	}
+	return nil
+}
+
+// tallyInput reads the whole input, tallying what the outputs require, and
+// applies any -reorder to the result. If the context is cancelled first it
+// stops reading, saving a -checkpoint to resume from, and returns
+// errInterrupted.
+func tallyInput(ctx context.Context, req requirements, aliases map[string]string) (*Tally, error) {
+	var reader rowReader
+	var merged *mergedReader
+	var err error
+	if args.Inputs != "" {
+		merged, err = openMergedReader(inputPaths())
+		if err != nil {
+			return nil, err
+		}
+		defer merged.Close()
+		reader = withDedup(merged)
+	} else {
+		in, err := openInput()
+		if err != nil {
+			return nil, err
+		}
+		defer in.Close()
+		reader, err = openReader(in)
+		if err != nil {
+			return nil, err
+		}
+	}
+	fieldNames := reader.Fields()
+	infow(logFields{"fields": len(fieldNames)}, "number of fields: %d\n", len(fieldNames))
+	if err := checkThresholds(fieldNames); err != nil {
+		return nil, err
+	}
+	var jointCols []int
+	if req.joints && args.JointColumns != "" {
+		jointCols, err = resolveJointColumns(args.JointColumns, fieldNames)
+		if err != nil {
+			return nil, fmt.Errorf("-joint-columns: %v", err)
+		}
+	}
+	numJointFields := len(fieldNames)
+	if jointCols != nil {
+		numJointFields = len(jointCols)
+	}
+	if err := checkMaxPairs(numJointFields, req.joints); err != nil {
+		return nil, err
+	}
+	tally := NewTally(fieldNames, req.joints && jointCols == nil)
+	if jointCols != nil {
+		tally.RestrictJoints(jointCols)
+	}
+	if req.columns {
+		tally.KeepColumns()
+	}
+	if args.Pairs != "" {
+		tally.Pairs, err = readPairs(args.Pairs, fieldNames)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if req.label {
+		tally.Label, err = labelIndex(fieldNames)
+		if err != nil {
+			return nil, err
+		}
+		if args.PredictInput != "" {
+			tally.predictInput, err = readPredictInput(args.PredictInput, fieldNames, tally.Label)
+			if err != nil {
+				return nil, err
 			}
-			// parse the row and tally marginal counts
-			for i, _ := range fieldNames {
-				val := fields[i+1]
-				if val == "0" {
-					row[i] = 0
-				} else if val == "1" {
-					row[i] = 1
-				} else {
-					log.Fatalf("invalid value '%s' on line %d\n", val, lineNum)
-				}
-				marginals[i] += row[i]
+		}
+	}
+	if req.target {
+		targets, err := resolveColumns(args.Target, fieldNames)
+		if err != nil {
+			return nil, fmt.Errorf("-target: %v", err)
+		}
+		tally.Target = targets[0]
+		if args.Parents != "" {
+			tally.Parents, err = resolveColumns(args.Parents, fieldNames)
+			if err != nil {
+				return nil, fmt.Errorf("-parents: %v", err)
 			}
-			// joint counts
-			if calcJoints {
-				for i, _ := range fieldNames {
-					for j, _ := range fieldNames {
-						if row[i]*row[j] == 1 {
-							joints[i][j] += 1
-						}
-					}
-				}
+		}
+		if jointCols != nil && args.MIRank != "" {
+			found := false
+			for _, i := range jointCols {
+				found = found || i == tally.Target
 			}
-			numReads += 1
+			if !found {
+				return nil, fmt.Errorf("-target '%s' must be one of the -joint-columns for -mi-rank", args.Target)
+			}
+		}
+	}
+	if req.anySet {
+		cols, err := resolveColumns(args.AnyOf, fieldNames)
+		if err != nil {
+			return nil, fmt.Errorf("-any-of: %v", err)
 		}
+		tally.AnySet = tally.TrackSet(cols)
 	}
-	// Print the marginals
-	if args.Marginals != "" {
-		for i, name := range fieldNames {
-			mar := float64(marginals[i]) / float64(numReads)
-			fmt.Fprintf(marFp, "P( %s ) = %0.6f ; %d\n", name, mar, marginals[i])
+	if req.allSet {
+		cols, err := resolveColumns(args.AllOf, fieldNames)
+		if err != nil {
+			return nil, fmt.Errorf("-all-of: %v", err)
 		}
+		tally.AllSet = tally.TrackSet(cols)
 	}
-	// Print the joint probabilities
-	if args.Joints != "" {
-		for i, iName := range fieldNames {
-			for j, jName := range fieldNames {
-				// P(A^B) = joint/numReads
-				jointProb := float64(joints[i][j]) / float64(numReads)
-				fmt.Fprintf(jointFp, "P( %s , %s ) = %0.8f ; %d\n", iName, jName, jointProb, joints[i][j])
+	if req.given {
+		cols, err := resolveColumns(args.Given, fieldNames)
+		if err != nil {
+			return nil, fmt.Errorf("-given: %v", err)
+		}
+		tally.GivenSet = tally.TrackGiven(cols)
+	}
+	var weighted weightedReader
+	if args.WeightCol != "" {
+		weighted = reader.(weightedReader)
+		tally.TrackWeights()
+	}
+	var multiple multiplicityReader
+	if args.Multiplicity {
+		multiple = reader.(multiplicityReader)
+		tally.Multiplicities = make(map[int]int)
+	}
+	var grouped groupedReader
+	if args.GroupBy != "" {
+		grouped = reader.(groupedReader)
+		tally.TrackGroups()
+	}
+	if args.Resume {
+		cp, err := readCheckpoint(args.Checkpoint)
+		if err != nil {
+			return nil, err
+		}
+		if err := tally.restore(cp); err != nil {
+			return nil, fmt.Errorf("can't resume from '%s': %v", args.Checkpoint, err)
+		}
+		// Skip the reads that were already tallied
+		skipped := 0
+		for skipped < cp.Reads && reader.Scan() {
+			skipped++
+		}
+		if skipped < cp.Reads {
+			if err := reader.Err(); err != nil {
+				return nil, err
 			}
+			return nil, fmt.Errorf("can't resume from '%s': the input has only %d of its %d reads",
+				args.Checkpoint, skipped, cp.Reads)
 		}
+		infow(logFields{"rows": cp.Reads}, "resumed after %d rows\n", cp.Reads)
 	}
-	// Print the conditional probabilities
-	if args.Conditionals != "" {
-		for i, iName := range fieldNames {
-			for j, jName := range fieldNames {
-				// P(A^B) = joint/numReads
-				// P(A | B) = P(A^B) / P(B)
-				if marginals[i] == 0 {
-					fmt.Fprintf(condFp, "P( %s | %s ) = NaN ; %d , %d\n", jName, iName, joints[i][j], marginals[i])
-					continue
+	start := time.Now()
+	lastFlush := start
+	mark := start
+	for reader.Scan() {
+		if args.Timings {
+			mark = lap(&timings.parse, mark)
+		}
+		read := ""
+		if req.columns {
+			read = reader.Read()
+		}
+		times := 1
+		if multiple != nil {
+			times = multiple.Multiplicity()
+		}
+		tally.AddTimes(read, reader.Row(), times)
+		if weighted != nil {
+			tally.AddWeight(reader.Row(), weighted.Weight()*float64(times))
+		}
+		if grouped != nil {
+			tally.AddGroup(reader.Row(), grouped.Group(), times)
+		}
+		if args.Timings {
+			mark = lap(&timings.accumulate, mark)
+		}
+		if args.Verbose && tally.NumReads%100000 == 0 {
+			verbosew(logFields{"rows": tally.NumReads}, "read %d rows\n", tally.NumReads)
+		}
+		if args.FlushInterval > 0 && time.Since(lastFlush) >= args.FlushInterval {
+			if err := writeSnapshot(tally, displayNames(tally.FieldNames, aliases)); err != nil {
+				return nil, err
+			}
+			verbosew(logFields{"rows": tally.NumReads}, "flushed outputs after %d rows\n", tally.NumReads)
+			lastFlush = time.Now()
+			if args.Timings {
+				mark = lap(&timings.write, mark)
+			}
+		}
+		if args.FlushEvery > 0 && tally.NumReads%args.FlushEvery == 0 {
+			if err := writeFlushed(tally, displayNames(tally.FieldNames, aliases)); err != nil {
+				return nil, err
+			}
+			verbosew(logFields{"rows": tally.NumReads}, "flushed outputs after %d rows\n", tally.NumReads)
+			if args.Timings {
+				mark = lap(&timings.write, mark)
+			}
+		}
+		if tally.NumReads%interruptCheckEvery == 0 && ctx.Err() != nil {
+			if args.Checkpoint != "" {
+				if err := writeCheckpoint(tally, args.Checkpoint); err != nil {
+					return nil, err
 				}
-				jointProb := float64(joints[i][j]) / float64(numReads)
-				mar := float64(marginals[i]) / float64(numReads)
-				condProb := jointProb / mar
-				fmt.Fprintf(condFp, "P( %s | %s ) = %0.8f ; %d , %d\n", jName, iName, condProb, joints[i][j], marginals[i])
 			}
+			return nil, fmt.Errorf("%w after %d reads", errInterrupted, tally.NumReads)
 		}
+		if args.Checkpoint != "" && tally.NumReads%args.CheckpointEvery == 0 {
+			if err := writeCheckpoint(tally, args.Checkpoint); err != nil {
+				return nil, err
+			}
+			verbosew(logFields{"rows": tally.NumReads}, "checkpointed after %d rows\n", tally.NumReads)
+			if args.Timings {
+				mark = lap(&timings.write, mark)
+			}
+		}
+	}
+	if args.Timings {
+		lap(&timings.parse, mark)
+	}
+	if err := reader.Err(); err != nil {
+		return nil, err
+	}
+	if counter, ok := reader.(missingCounter); ok && args.MissingValue != "" {
+		tally.Missing = make([]int, len(fieldNames))
+		for i, n := range counter.MissingCounts() {
+			tally.Missing[i] = int(n)
+		}
+	}
+	if merged != nil {
+		tally.Sources = inputPaths()
+		tally.SourceReads = merged.Counts
+	}
+	if args.AllErrors && badRows.report() > 0 {
+		return nil, fmt.Errorf("the input is invalid")
+	}
+	if inputHash != nil {
+		// Hash the whole input for the -manifest even if -limit stopped
+		// reading it early
+		if _, err := io.Copy(io.Discard, inputHash); err != nil {
+			return nil, err
+		}
+	}
+	verbosew(logFields{"rows": tally.NumReads, "seconds": time.Since(start).Seconds()},
+		"read %d rows in %v\n", tally.NumReads, since(start))
+	if args.Reorder != "" {
+		tally.Permute(leafOrder(tally.Clustering(), len(fieldNames)))
+	} else if args.SortCols {
+		tally.Permute(prevalenceOrder(tally))
+	} else if args.Stable {
+		tally.Permute(nameOrder(displayNames(tally.FieldNames, aliases)))
 	}
+	return tally, nil
 }
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// A sourcedRow is a read from one of the -inputs, or the error that ended
+// it.
+type sourcedRow struct {
+	source int
+	row    bufferedRow
+	err    error
+}
+
+// mergedReader reads the -inputs all at once, one goroutine each, and
+// serves their reads as one input, in whatever order they arrive. Each of
+// them must have the same header. It is meant for named pipes written by
+// producers running side by side, where reading them in turn would hold
+// up every producer but the one being read.
+type mergedReader struct {
+	paths      []string
+	files      []*os.File
+	fieldNames []string
+	rows       chan sourcedRow
+	quit       chan struct{}
+	missing    []int64
+	mu         sync.Mutex
+	// Counts holds the number of reads served from each input
+	Counts []int
+	row    bufferedRow
+	err    error
+}
+
+// openMergedReader opens every one of the -inputs, checking that they have
+// the same header, and starts reading them.
+func openMergedReader(paths []string) (*mergedReader, error) {
+	mr := &mergedReader{
+		paths:  paths,
+		rows:   make(chan sourcedRow, 64*len(paths)),
+		quit:   make(chan struct{}),
+		Counts: make([]int, len(paths)),
+	}
+	var readers []rowReader
+	for k, path := range paths {
+		fp, err := os.Open(path)
+		if err != nil {
+			mr.Close()
+			return nil, fmt.Errorf("failed to open input '%s': %v", path, err)
+		}
+		mr.files = append(mr.files, fp)
+		reader, err := openFormatReader(fp)
+		if err != nil {
+			mr.Close()
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if k == 0 {
+			mr.fieldNames = reader.Fields()
+		} else if !reflect.DeepEqual(reader.Fields(), mr.fieldNames) {
+			mr.Close()
+			return nil, fmt.Errorf("input '%s' has different columns from '%s'", path, paths[0])
+		}
+		readers = append(readers, reader)
+	}
+	var wg sync.WaitGroup
+	for k, reader := range readers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mr.readSource(k, reader)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(mr.rows)
+	}()
+	return mr, nil
+}
+
+// readSource queues every read of one input, and then any error that ended
+// it, adding its -missing-value counts to the others'.
+func (mr *mergedReader) readSource(k int, reader rowReader) {
+	for reader.Scan() {
+		select {
+		case mr.rows <- sourcedRow{source: k, row: copyRow(reader)}:
+		case <-mr.quit:
+			return
+		}
+	}
+	if counter, ok := reader.(missingCounter); ok {
+		mr.mu.Lock()
+		if mr.missing == nil {
+			mr.missing = make([]int64, len(mr.fieldNames))
+		}
+		for i, n := range counter.MissingCounts() {
+			mr.missing[i] += n
+		}
+		mr.mu.Unlock()
+	}
+	if err := reader.Err(); err != nil {
+		select {
+		case mr.rows <- sourcedRow{source: k, err: fmt.Errorf("%s: %w", mr.paths[k], err)}:
+		case <-mr.quit:
+		}
+	}
+}
+
+func (mr *mergedReader) Fields() []string {
+	return mr.fieldNames
+}
+
+func (mr *mergedReader) Scan() bool {
+	if mr.err != nil {
+		return false
+	}
+	sr, ok := <-mr.rows
+	if !ok {
+		return false
+	}
+	if sr.err != nil {
+		mr.err = sr.err
+		close(mr.quit)
+		return false
+	}
+	mr.Counts[sr.source]++
+	mr.row = sr.row
+	return true
+}
+
+func (mr *mergedReader) Read() string {
+	return mr.row.read
+}
+
+func (mr *mergedReader) Row() []int {
+	return mr.row.row
+}
+
+func (mr *mergedReader) Weight() float64 {
+	return mr.row.weight
+}
+
+func (mr *mergedReader) Group() string {
+	return mr.row.group
+}
+
+// MissingCounts sums the -missing-value counts of the inputs, once they
+// have all been read.
+func (mr *mergedReader) MissingCounts() []int64 {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	if mr.missing == nil {
+		return make([]int64, len(mr.fieldNames))
+	}
+	return mr.missing
+}
+
+func (mr *mergedReader) Err() error {
+	return mr.err
+}
+
+// Close stops the reading and closes the inputs.
+func (mr *mergedReader) Close() error {
+	select {
+	case <-mr.quit:
+	default:
+		close(mr.quit)
+	}
+	for _, fp := range mr.files {
+		fp.Close()
+	}
+	return nil
+}
+
+// inputPaths splits the -inputs list.
+func inputPaths() []string {
+	return strings.Split(args.Inputs, ",")
+}
@@ -0,0 +1,19 @@
+//go:build !unix
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+type mappedFile struct {
+	io.ReadCloser
+}
+
+// mmapFile always fails where memory mapping isn't supported, so the input
+// is read normally.
+func mmapFile(fp *os.File) (*mappedFile, error) {
+	return nil, fmt.Errorf("memory mapping isn't supported on this platform")
+}
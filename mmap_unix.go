@@ -0,0 +1,45 @@
+//go:build unix
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// A mappedFile reads the contents of a memory-mapped file.
+type mappedFile struct {
+	*bytes.Reader
+	data []byte
+	fp   *os.File
+}
+
+// mmapFile maps the whole of a regular file into memory.
+func mmapFile(fp *os.File) (*mappedFile, error) {
+	fi, err := fp.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("not a regular file")
+	}
+	if fi.Size() == 0 {
+		return nil, fmt.Errorf("the file is empty")
+	}
+	data, err := syscall.Mmap(int(fp.Fd()), 0, int(fi.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	return &mappedFile{bytes.NewReader(data), data, fp}, nil
+}
+
+// Close unmaps the file and closes it.
+func (m *mappedFile) Close() error {
+	err := syscall.Munmap(m.data)
+	if cerr := m.fp.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
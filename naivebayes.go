@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// An nbModel is a naive Bayes classifier for a 0/1 label column, giving the
+// smoothed probability of each class and of each feature column being 1
+// within each class. Every column but the label is a feature.
+type nbModel struct {
+	label    int
+	features []int
+	// prior[c] is P(label = c)
+	prior [2]float64
+	// likelihood[c][k] is P(feature k = 1 | label = c)
+	likelihood [2][]float64
+	// the counts the above were estimated from
+	classCount   [2]int
+	featureCount [2][]int
+}
+
+// trainNaiveBayes estimates a naive Bayes model for the label column from
+// the marginal and joint counts.
+func trainNaiveBayes(t *Tally, label int) *nbModel {
+	classCount := [2]int{t.NumReads - t.Marginals[label], t.Marginals[label]}
+	var ones [2][]int
+	for j := range t.FieldNames {
+		ones[0] = append(ones[0], t.Marginals[j]-t.Joints[label][j])
+		ones[1] = append(ones[1], t.Joints[label][j])
+	}
+	return newNBModel(label, classCount, ones)
+}
+
+// newNBModel estimates a naive Bayes model from the number of reads in each
+// class and, for each class, the number of those reads where each column
+// is 1.
+func newNBModel(label int, classCount [2]int, ones [2][]int) *nbModel {
+	m := &nbModel{label: label, classCount: classCount}
+	n := classCount[0] + classCount[1]
+	for c := 0; c < 2; c++ {
+		m.prior[c] = smoothedProb(classCount[c], n, 2)
+	}
+	for j := range ones[0] {
+		if j == label {
+			continue
+		}
+		m.features = append(m.features, j)
+		for c := 0; c < 2; c++ {
+			m.featureCount[c] = append(m.featureCount[c], ones[c][j])
+			m.likelihood[c] = append(m.likelihood[c], smoothedProb(ones[c][j], classCount[c], 2))
+		}
+	}
+	return m
+}
+
+// smoothedProb returns the proportion count/total of one of k possible
+// outcomes, adding -smoothing pseudo-counts to each outcome.
+func smoothedProb(count, total, k int) float64 {
+	return (float64(count) + args.Smoothing) / (float64(total) + float64(k)*args.Smoothing)
+}
+
+// labelIndex looks up the -label column.
+func labelIndex(fieldNames []string) (int, error) {
+	for i, name := range fieldNames {
+		if name == args.Label {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown label column '%s'", args.Label)
+}
+
+// writeNaiveBayes prints the naive Bayes model trained for the -label
+// column: a prior row for each class, then a likelihood row for each
+// feature and class giving the probability that the feature is 1.
+func writeNaiveBayes(w io.Writer, t *Tally, names []string) {
+	m := t.NaiveBayes()
+	fmt.Fprintln(w, "type\tclass\tfeature\tprobability\tcount\ttotal")
+	for c := 1; c >= 0; c-- {
+		fmt.Fprintf(w, "prior\t%d\t%s\t%s\t%d\t%d\n", c, names[m.label], formatValue(m.prior[c], 'f', 8), m.classCount[c], t.NumReads)
+	}
+	for k, j := range m.features {
+		for c := 1; c >= 0; c-- {
+			fmt.Fprintf(w, "likelihood\t%d\t%s\t%s\t%d\t%d\n",
+				c, names[j], formatValue(m.likelihood[c][k], 'f', 8), m.featureCount[c][k], m.classCount[c])
+		}
+	}
+}
+
+// posterior returns P(label = 1 | features) for a row of values indexed
+// like the training columns. The class log-probabilities are accumulated in
+// log space so that many features don't underflow.
+func (m *nbModel) posterior(row []int) float64 {
+	var logPost [2]float64
+	for c := 0; c < 2; c++ {
+		logPost[c] = math.Log(m.prior[c])
+		for k, j := range m.features {
+			p := m.likelihood[c][k]
+			if row[j] == 0 {
+				p = 1 - p
+			}
+			logPost[c] += math.Log(p)
+		}
+	}
+	return 1 / (1 + math.Exp(logPost[0]-logPost[1]))
+}
+
+// A prediction is the naive Bayes score of one read. Actual is the read's
+// label, or -1 when the input has no label column.
+type prediction struct {
+	read   string
+	score  float64
+	actual int
+}
+
+// Predictions returns the naive Bayes predictions of the label for each
+// read, computing them the first time they are needed. The reads scored are
+// those of -predict-input if given, or else the training reads themselves.
+func (t *Tally) Predictions() []prediction {
+	if t.predictions != nil {
+		return t.predictions
+	}
+	m := t.NaiveBayes()
+	if t.predictInput != nil {
+		t.predictions = t.predictInput.predict(m, t.FieldNames)
+		return t.predictions
+	}
+	row := make([]int, len(t.FieldNames))
+	t.predictions = make([]prediction, t.NumReads)
+	for r := 0; r < t.NumReads; r++ {
+		t.readRow(r, row)
+		t.predictions[r] = prediction{t.Reads[r], m.posterior(row), row[m.label]}
+	}
+	return t.predictions
+}
+
+// predictInput holds the reads of a separate wide format input to score.
+// It is read in full before the training reads, so that any problem with
+// it is found before an output is written.
+type predictInput struct {
+	index map[string]int
+	reads []string
+	rows  [][]int
+}
+
+// readPredictInput reads the -predict-input. It must have every feature
+// column the model is trained on, in any order, and may omit the label
+// column.
+func readPredictInput(path string, fieldNames []string, label int) (*predictInput, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open prediction input '%s': %v", path, err)
+	}
+	defer fp.Close()
+	reader, err := newWideReader(fp, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	in := &predictInput{index: make(map[string]int)}
+	for i, name := range reader.Fields() {
+		in.index[name] = i
+	}
+	for j, name := range fieldNames {
+		if _, ok := in.index[name]; !ok && j != label {
+			return nil, fmt.Errorf("prediction input '%s' has no column '%s'", path, name)
+		}
+	}
+	for reader.Scan() {
+		in.reads = append(in.reads, reader.Read())
+		in.rows = append(in.rows, append([]int(nil), reader.Row()...))
+	}
+	if err := reader.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return in, nil
+}
+
+// predict scores the reads of the input, finding the training columns by
+// name since they may have been reordered since it was read.
+func (in *predictInput) predict(m *nbModel, fieldNames []string) []prediction {
+	// position[j] is where training column j is in the prediction input
+	position := make([]int, len(fieldNames))
+	for j, name := range fieldNames {
+		i, ok := in.index[name]
+		if !ok {
+			i = -1
+		}
+		position[j] = i
+	}
+	preds := make([]prediction, len(in.rows))
+	row := make([]int, len(fieldNames))
+	for r, values := range in.rows {
+		for j, i := range position {
+			if i >= 0 {
+				row[j] = values[i]
+			}
+		}
+		actual := -1
+		if position[m.label] >= 0 {
+			actual = row[m.label]
+		}
+		preds[r] = prediction{in.reads[r], m.posterior(row), actual}
+	}
+	return preds
+}
+
+// predictedClass returns the class predicted for a score at the
+// -decision-threshold.
+func predictedClass(score float64) int {
+	if score > args.DecisionThreshold {
+		return 1
+	}
+	return 0
+}
+
+// writePredictions prints each read's posterior probability of each class,
+// the class predicted at the -decision-threshold and the actual class if
+// known.
+func writePredictions(w io.Writer, t *Tally, names []string) {
+	fmt.Fprintln(w, "read\tpredicted\tposterior_0\tposterior_1\tactual")
+	for _, p := range t.Predictions() {
+		predicted := predictedClass(p.score)
+		actual := "NA"
+		if p.actual >= 0 {
+			actual = fmt.Sprint(p.actual)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\n", p.read, predicted, formatValue(1-p.score, 'f', 8), formatValue(p.score, 'f', 8), actual)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// onehotReader parses wide format input in which one -onehot column is
+// categorical rather than 0/1. The column is replaced by one indicator
+// column named 'column=value' for each of its distinct values, which come
+// after the other columns in sorted order. Learning the values takes a
+// first pass, so the whole input is held in memory.
+type onehotReader struct {
+	fieldNames []string
+	thresholds []float64
+	// column is the position of the categorical column among the input's
+	// fields, not counting the read name
+	column   int
+	category map[string]int
+	lines    []string
+	lineNums []int
+	pos      int
+	read     string
+	row      []int
+	err      error
+}
+
+func newOnehotReader(r io.Reader, limit int, column string) (*onehotReader, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() {
+		return &onehotReader{pos: -1}, scanner.Err()
+	}
+	// This should be the header line
+	header := splitHeader(scanner.Text())
+	if len(header) < 2 {
+		return nil, fmt.Errorf("too few fields")
+	}
+	if header[0] != "read" {
+		return nil, fmt.Errorf("first field should be named 'read'")
+	}
+	column = columnName(column)
+	or := &onehotReader{column: -1, category: make(map[string]int), pos: -1}
+	for i, name := range header[1:] {
+		name = columnName(name)
+		if name == column {
+			or.column = i
+		} else {
+			or.fieldNames = append(or.fieldNames, name)
+		}
+	}
+	if or.column < 0 {
+		return nil, fmt.Errorf("there is no -onehot column '%s'", column)
+	}
+	// Learn the categories while holding on to the lines
+	lineNum := 1
+	var values []string
+	for scanner.Scan() {
+		if limit > 0 && lineNum > limit {
+			break
+		}
+		lineNum++
+		line := scanner.Text()
+		fields := strings.Split(line, "\t")
+		if len(fields) != len(header) {
+			return nil, fmt.Errorf("expected line %d to have %d fields", lineNum, len(header))
+		}
+		value := fields[or.column+1]
+		if _, ok := or.category[value]; !ok {
+			or.category[value] = 0
+			values = append(values, value)
+		}
+		or.lines = append(or.lines, line)
+		or.lineNums = append(or.lineNums, lineNum)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(values)
+	numOthers := len(or.fieldNames)
+	for k, value := range values {
+		or.category[value] = numOthers + k
+		or.fieldNames = append(or.fieldNames, columnName(column+"="+value))
+	}
+	// The indicator columns may clash with the others, or with each other
+	// once lowercased
+	if err := checkDuplicateNames(or.fieldNames); err != nil {
+		return nil, err
+	}
+	or.thresholds = thresholdsFor(or.fieldNames[:numOthers])
+	or.row = make([]int, len(or.fieldNames))
+	return or, nil
+}
+
+func (or *onehotReader) Fields() []string {
+	return or.fieldNames
+}
+
+func (or *onehotReader) Scan() bool {
+	if or.err != nil || or.pos+1 >= len(or.lines) {
+		return false
+	}
+	or.pos++
+	lineNum := or.lineNums[or.pos]
+	fields := strings.Split(or.lines[or.pos], "\t")
+	or.read = fields[0]
+	for i := range or.row {
+		or.row[i] = 0
+	}
+	k := 0
+	for i, val := range fields[1:] {
+		if i == or.column {
+			or.row[or.category[val]] = 1
+			continue
+		}
+		or.row[k], or.err = parseIndicator([]byte(val), or.thresholds[k], lineNum)
+		if or.err != nil {
+			return false
+		}
+		k++
+	}
+	return true
+}
+
+func (or *onehotReader) Read() string {
+	return or.read
+}
+
+func (or *onehotReader) Row() []int {
+	return or.row
+}
+
+func (or *onehotReader) Err() error {
+	return or.err
+}
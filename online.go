@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// writeSnapshot replaces every selected output with the results of the
+// reads tallied so far. Outputs are rewritten only between reads, so a
+// stream that stalls keeps its last snapshot until more reads arrive.
+func writeSnapshot(t *Tally, names []string) error {
+	t.resetCache()
+	for _, out := range selectedOutputs() {
+		if err := replaceOutput(out, t, names); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushedEvery reports whether -flush-every rewrites the output as the
+// reads are tallied.
+func (out *output) flushedEvery() bool {
+	return args.FlushEvery > 0 && (out.path == &args.Marginals || out.path == &args.Joints)
+}
+
+// writeFlushed replaces the outputs that -flush-every rewrites with the
+// results of the reads tallied so far.
+func writeFlushed(t *Tally, names []string) error {
+	t.resetCache()
+	for _, out := range selectedOutputs() {
+		if !out.flushedEvery() {
+			continue
+		}
+		if err := replaceOutput(out, t, names); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceOutput replaces an output file with the results in the tally.
+// Outputs that aren't regular files, such as /dev/stdout, are simply
+// written to again.
+func replaceOutput(out *output, t *Tally, names []string) error {
+	if fi, err := os.Stat(*out.path); err == nil && !fi.Mode().IsRegular() {
+		out.writeSeeded(out.fp, t, names)
+		return nil
+	}
+	return replaceFile(*out.path, func(w io.Writer) {
+		out.writeSeeded(w, t, names)
+	})
+}
+
+// replaceFile writes a file's new contents to a temporary file in the same
+// directory and renames it over the file, so that anyone reading it sees
+// either the previous contents or the new ones and never a partial file.
+func replaceFile(path string, write func(w io.Writer)) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file for '%s': %v", path, err)
+	}
+	write(tmp)
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write '%s': %v", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to replace '%s': %v", path, err)
+	}
+	return nil
+}
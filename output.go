@@ -0,0 +1,423 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// An output is a file that one kind of result is written to. The joints
+// and columns fields record whether the result needs the joint counts to be
+// tallied, the column bitsets to be kept, the -any-of, -all-of or -given
+// column sets to be tracked, or a -label or -target column. Pairs records
+// whether an output that needs the joints can be restricted to the -pairs.
+type output struct {
+	name    string
+	path    *string
+	joints  bool
+	columns bool
+	anySet  bool
+	allSet  bool
+	given   bool
+	label   bool
+	target  bool
+	pairs   bool
+	write   func(w io.Writer, t *Tally, names []string)
+	fp      *os.File
+}
+
+var outputs = []*output{
+	{name: "marginals", path: &args.Marginals, write: writeMarginals},
+	{name: "weighted marginals", path: &args.WeightedMarginals, write: writeWeightedMarginals},
+	{name: "group difference", path: &args.GroupDiff, write: writeGroupDiff},
+	{name: "z-test", path: &args.ZTest, write: writeZTest},
+	{name: "missing report", path: &args.MissingReport, write: writeMissingReport},
+	{name: "prevalence", path: &args.Prevalence, write: writePrevalence},
+	{name: "any", path: &args.Any, anySet: true, write: writeAny},
+	{name: "all", path: &args.All, allSet: true, write: writeAll},
+	{name: "given conditionals", path: &args.GivenConditionals, given: true, write: writeGivenConditionals},
+	{name: "joints", path: &args.Joints, joints: true, pairs: true, write: writeJoints},
+	{name: "triples", path: &args.Triples, joints: true, write: writeTriples},
+	{name: "joints matrix", path: &args.JointsMatrix, joints: true, write: writeJointsMatrix},
+	{name: "conditionals", path: &args.Conditionals, joints: true, pairs: true, write: writeConditionals},
+	{name: "rules", path: &args.Rules, joints: true, write: writeRules},
+	{name: "itemsets", path: &args.Itemsets, columns: true, write: writeItemsets},
+	{name: "closed itemsets", path: &args.ClosedItemsets, columns: true, write: writeClosedItemsets},
+	{name: "mcc", path: &args.MCC, joints: true, write: writeMCC},
+	{name: "correlation matrix", path: &args.CorrelationMatrix, joints: true, write: writeCorrelationMatrix},
+	{name: "jaccard distances", path: &args.JaccardDistances, joints: true, write: writeJaccardDistances},
+	{name: "phylip", path: &args.Phylip, joints: true, write: writePhylip},
+	{name: "contingency tables", path: &args.ContingencyLong, joints: true, write: writeContingencyLong},
+	{name: "yule", path: &args.Yule, joints: true, write: writeYule},
+	{name: "chi-square", path: &args.ChiSquare, joints: true, write: writeChiSquare},
+	{name: "tetrachoric", path: &args.Tetrachoric, joints: true, write: writeTetrachoric},
+	{name: "enrichment", path: &args.Enrichment, joints: true, write: writeEnrichment},
+	{name: "theil", path: &args.TheilU, joints: true, write: writeTheilU},
+	{name: "lambda", path: &args.Lambda, joints: true, write: writeLambda},
+	{name: "somers", path: &args.SomersD, joints: true, write: writeSomersD},
+	{name: "infogain", path: &args.InfoGain, joints: true, write: writeInfoGain},
+	{name: "MI ranking", path: &args.MIRank, joints: true, target: true, write: writeMIRank},
+	{name: "Chow-Liu tree", path: &args.ChowLiu, joints: true, write: writeChowLiu},
+	{name: "Chow-Liu edges", path: &args.ChowLiuEdges, joints: true, write: writeChowLiuEdges},
+	{name: "Chow-Liu dot", path: &args.ChowLiuDot, joints: true, write: writeChowLiuDot},
+	{name: "KL divergence", path: &args.KL, write: writeKL},
+	{name: "JS divergence", path: &args.JS, write: writeJS},
+	{name: "dot", path: &args.Dot, joints: true, write: writeDot},
+	{name: "edges", path: &args.EdgeList, joints: true, write: writeEdgeList},
+	{name: "graphml", path: &args.GraphML, joints: true, write: writeGraphML},
+	{name: "bayes", path: &args.Bayes, write: writeBayes},
+	{name: "credible", path: &args.Credible, write: writeCredible},
+	{name: "merges", path: &args.Merges, joints: true, write: writeMerges},
+	{name: "clusters", path: &args.Clusters, joints: true, write: writeClusters},
+	{name: "reorder", path: &args.Reorder, joints: true, write: writeReorder},
+	{name: "anticorrelated", path: &args.AntiCorrelated, joints: true, write: writeAntiCorrelated},
+	{name: "hamming", path: &args.Hamming, joints: true, write: writeHamming},
+	{name: "near duplicates", path: &args.NearDuplicates, columns: true, write: writeNearDuplicates},
+	{name: "anomalies", path: &args.Anomalies, columns: true, write: writeAnomalies},
+	{name: "permutation test", path: &args.Permutation, columns: true, write: writePermutation},
+	{name: "loo", path: &args.LOO, joints: true, columns: true, write: writeLOO},
+	{name: "naive Bayes model", path: &args.NBModel, joints: true, label: true, write: writeNaiveBayes},
+	{name: "predictions", path: &args.Predict, joints: true, columns: true, label: true, write: writePredictions},
+	{name: "cross-validation", path: &args.CVReport, columns: true, label: true, write: writeCrossValidation},
+	{name: "confusion matrix", path: &args.Confusion, joints: true, columns: true, label: true, write: writeConfusion},
+	{name: "ROC curve", path: &args.ROC, joints: true, columns: true, label: true, write: writeROC},
+	{name: "AUC", path: &args.AUC, joints: true, columns: true, label: true, write: writeAUC},
+	{name: "precision-recall curve", path: &args.PR, joints: true, columns: true, label: true, write: writePrecisionRecall},
+	{name: "CPT", path: &args.CPT, columns: true, target: true, write: writeCPT},
+	{name: "sqlite", path: &args.SQLite, joints: true, columns: true, write: writeSQLite},
+}
+
+// writeSeeded writes the output with the random source freshly seeded from
+// -seed, so that the same input and seed always give identical output.
+func (out *output) writeSeeded(w io.Writer, t *Tally, names []string) {
+	random = rand.New(rand.NewSource(args.Seed))
+	if out.joints && t.JointCols != nil {
+		// Only the joints among the -joint-columns were counted
+		t, names = t.jointView(names)
+	}
+	out.write(w, t, names)
+}
+
+// requirements are what the selected outputs need from the tally, combined.
+type requirements struct {
+	joints  bool
+	columns bool
+	anySet  bool
+	allSet  bool
+	given   bool
+	label   bool
+	target  bool
+}
+
+// selectedOutputs returns the outputs that were given a file to write to.
+func selectedOutputs() []*output {
+	var selected []*output
+	for _, out := range outputs {
+		if *out.path != "" {
+			selected = append(selected, out)
+		}
+	}
+	return selected
+}
+
+// formatValue formats a statistic like strconv.FormatFloat, except that an
+// undefined NaN value is written as the -na-token. Every statistic other
+// than a probability is formatted with it, so that undefined values are
+// written the same way in every output.
+func formatValue(x float64, format byte, prec int) string {
+	if math.IsNaN(x) {
+		return args.NaToken
+	}
+	return strconv.FormatFloat(x, format, prec, 64)
+}
+
+// formatProb formats a probability with the given number of decimal places,
+// or as a percentage when -percent is given. A non-zero -precision overrides
+// the number of decimal places. With -sci the probability is written in
+// scientific notation with that many significant digits instead, so that
+// very small probabilities don't round to zero.
+func formatProb(p float64, decimals int) string {
+	if math.IsNaN(p) {
+		return args.NaToken
+	}
+	if args.Precision > 0 {
+		decimals = args.Precision
+	}
+	suffix := ""
+	if args.Percent {
+		p *= 100
+		suffix = "%"
+	}
+	if args.Sci {
+		return strconv.FormatFloat(p, 'e', decimals-1, 64) + suffix
+	}
+	return strconv.FormatFloat(p, 'f', decimals, 64) + suffix
+}
+
+// writeMarginals prints the marginal probability of each indicator.
+func writeMarginals(w io.Writer, t *Tally, names []string) {
+	if args.Pretty {
+		writeMarginalsTable(w, t, names)
+		return
+	}
+	if args.RTable {
+		writeMarginalsRTable(w, t, names)
+		return
+	}
+	for i, name := range names {
+		mar := float64(t.Marginals[i]) / float64(t.NumReads)
+		fmt.Fprintf(w, "P( %s ) = %s ; %d\n", name, formatProb(mar, 6), t.Marginals[i])
+	}
+}
+
+// writeMissingReport prints the number of -missing-value cells of each
+// column and the number of reads where it was observed.
+func writeMissingReport(w io.Writer, t *Tally, names []string) {
+	fmt.Fprintln(w, "column\tmissing\tobserved")
+	for i, name := range names {
+		fmt.Fprintf(w, "%s\t%d\t%d\n", name, t.Missing[i], t.NumReads-t.Missing[i])
+	}
+}
+
+// prevalenceOrder returns the columns from the most to the least common,
+// keeping columns that are equally common in their current order.
+func prevalenceOrder(t *Tally) []int {
+	order := make([]int, len(t.Marginals))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return t.Marginals[order[a]] > t.Marginals[order[b]]
+	})
+	return order
+}
+
+// nameOrder returns the columns in lexicographic order of their names.
+func nameOrder(names []string) []int {
+	order := make([]int, len(names))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return names[order[a]] < names[order[b]]
+	})
+	return order
+}
+
+// writePrevalence prints the columns from the most to the least common, with
+// their marginal probabilities and counts.
+func writePrevalence(w io.Writer, t *Tally, names []string) {
+	fmt.Fprintln(w, "column\tprobability\tcount")
+	for _, i := range prevalenceOrder(t) {
+		mar := float64(t.Marginals[i]) / float64(t.NumReads)
+		fmt.Fprintf(w, "%s\t%s\t%d\n", names[i], formatProb(mar, 6), t.Marginals[i])
+	}
+}
+
+// writeJoints prints the joint probability of every ordered pair of
+// indicators, followed by its standard error with -joint-se.
+func writeJoints(w io.Writer, t *Tally, names []string) {
+	if args.Pretty {
+		writeJointsTable(w, t, names)
+		return
+	}
+	if args.RTable {
+		writeJointsRTable(w, t, names)
+		return
+	}
+	if args.JointBlocks > 0 {
+		writeBlockJoints(w, t, names)
+		return
+	}
+	for _, pair := range t.jointPairs() {
+		i, j := pair[0], pair[1]
+		writeJointLine(w, names[i], names[j], t.Joints[i][j], t.NumReads)
+	}
+}
+
+// writeJointLine prints the joint probability of one pair of indicators.
+func writeJointLine(w io.Writer, iName, jName string, joint, numReads int) {
+	// P(A^B) = joint/numReads
+	jointProb := float64(joint) / float64(numReads)
+	if args.JointSE {
+		fmt.Fprintf(w, "P( %s , %s ) = %s ; %d ; %s\n", iName, jName, formatProb(jointProb, 8), joint,
+			formatProb(binomialSE(jointProb, numReads), 8))
+		return
+	}
+	fmt.Fprintf(w, "P( %s , %s ) = %s ; %d\n", iName, jName, formatProb(jointProb, 8), joint)
+}
+
+// binomialSE returns the standard error sqrt(p(1-p)/n) of a proportion p
+// estimated from n reads.
+func binomialSE(p float64, n int) float64 {
+	return math.Sqrt(p * (1 - p) / float64(n))
+}
+
+// writeTriples prints the non-zero joint counts as a sparse matrix of
+// triples, leaving out every pair of columns that are never 1 together.
+func writeTriples(w io.Writer, t *Tally, names []string) {
+	for i, iName := range names {
+		for j, jName := range names {
+			if args.Triangle && j < i {
+				continue
+			}
+			if t.Joints[i][j] != 0 {
+				fmt.Fprintf(w, "%s\t%s\t%d\n", iName, jName, t.Joints[i][j])
+			}
+		}
+	}
+}
+
+// writeMatrix prints a square matrix of cells with a header row of column
+// names and one labeled row per column, or just the cells with
+// -header=false.
+func writeMatrix(w io.Writer, names []string, cell func(i, j int) string) {
+	if args.Header {
+		fmt.Fprintf(w, "\t%s\n", strings.Join(names, "\t"))
+	}
+	for i, name := range names {
+		if args.Header {
+			fmt.Fprintf(w, "%s\t", name)
+		}
+		for j := range names {
+			if j > 0 {
+				fmt.Fprint(w, "\t")
+			}
+			fmt.Fprint(w, cell(i, j))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// writeJointsMatrix prints the joint probabilities as a square matrix.
+func writeJointsMatrix(w io.Writer, t *Tally, names []string) {
+	writeMatrix(w, names, func(i, j int) string {
+		return formatProb(float64(t.Joints[i][j])/float64(t.NumReads), 8)
+	})
+}
+
+// writeConditionals prints the probability of each indicator conditioned on
+// every indicator, followed by its Wilson interval with -conditional-ci.
+func writeConditionals(w io.Writer, t *Tally, names []string) {
+	if args.Bidirectional {
+		writeBidirectionalConditionals(w, t, names)
+		return
+	}
+	if args.Pretty {
+		writeConditionalsTable(w, t, names)
+		return
+	}
+	if args.RTable {
+		writeConditionalsRTable(w, t, names)
+		return
+	}
+	for _, pair := range t.conditionalPairs() {
+		i, j := pair[0], pair[1]
+		iName, jName := names[i], names[j]
+		// P(A^B) = joint/numReads
+		// P(A | B) = P(A^B) / P(B)
+		ci := ""
+		if args.ConditionalCI {
+			lo, hi := wilsonInterval(t.Joints[i][j], t.Marginals[i], args.CILevel)
+			ci = fmt.Sprintf(" ; [ %s , %s ]", formatProb(lo, 8), formatProb(hi, 8))
+		}
+		if t.Marginals[i] == 0 {
+			fmt.Fprintf(w, "P( %s | %s ) = %s ; %d , %d%s\n", jName, iName, args.NaToken, t.Joints[i][j], t.Marginals[i], ci)
+			continue
+		}
+		jointProb := float64(t.Joints[i][j]) / float64(t.NumReads)
+		mar := float64(t.Marginals[i]) / float64(t.NumReads)
+		condProb := jointProb / mar
+		fmt.Fprintf(w, "P( %s | %s ) = %s ; %d , %d%s\n", jName, iName, formatProb(condProb, 8), t.Joints[i][j], t.Marginals[i], ci)
+	}
+}
+
+// writeBidirectionalConditionals prints both conditional probabilities of
+// every unordered pair of distinct indicators on one line, P(B|A) then
+// P(A|B), followed by the joint count and the count of each indicator. A
+// direction whose condition is 1 in fewer than -minsupport reads is written
+// as undefined, and the pair is left out if both are, or with
+// -skip-zero-joints if the pair is never 1 together.
+func writeBidirectionalConditionals(w io.Writer, t *Tally, names []string) {
+	seen := make(map[[2]int]bool)
+	for _, pair := range t.pairList(true) {
+		i, j := pair[0], pair[1]
+		if i > j {
+			i, j = j, i
+		}
+		if i == j || seen[[2]int{i, j}] {
+			continue
+		}
+		seen[[2]int{i, j}] = true
+		if t.Marginals[i] < args.MinSupport && t.Marginals[j] < args.MinSupport {
+			continue
+		}
+		if args.SkipZeroJoints && t.Joints[i][j] == 0 {
+			continue
+		}
+		given := func(c int) string {
+			if t.Marginals[c] < args.MinSupport {
+				return args.NaToken
+			}
+			return formatProb(float64(t.Joints[i][j])/float64(t.Marginals[c]), 8)
+		}
+		fmt.Fprintf(w, "P( %s | %s ) = %s ; P( %s | %s ) = %s ; %d , %d , %d\n",
+			names[j], names[i], given(i), names[i], names[j], given(j), t.Joints[i][j], t.Marginals[i], t.Marginals[j])
+	}
+}
+
+// jointPairs returns the pairs of columns that joints are written for,
+// leaving out each column paired with itself with -self=false, and those
+// that are never 1 together with -skip-zero-joints.
+func (t *Tally) jointPairs() [][2]int {
+	var pairs [][2]int
+	for _, pair := range t.pairList(args.Triangle) {
+		if !args.Self && pair[0] == pair[1] {
+			continue
+		}
+		if !args.SkipZeroJoints || t.Joints[pair[0]][pair[1]] > 0 {
+			pairs = append(pairs, pair)
+		}
+	}
+	return pairs
+}
+
+// conditionalPairs returns the pairs of columns that conditionals are
+// written for, leaving out those whose condition is 1 in fewer than
+// -minsupport reads, each column paired with itself with -self=false, and
+// with -skip-zero-joints those that are never 1 together.
+func (t *Tally) conditionalPairs() [][2]int {
+	var pairs [][2]int
+	for _, pair := range t.pairList(false) {
+		if t.Marginals[pair[0]] < args.MinSupport || (!args.Self && pair[0] == pair[1]) {
+			continue
+		}
+		if !args.SkipZeroJoints || t.Joints[pair[0]][pair[1]] > 0 {
+			pairs = append(pairs, pair)
+		}
+	}
+	return pairs
+}
+
+// wilsonInterval returns the Wilson score interval covering the given level
+// of probability for a proportion of k successes in n trials, which is NaN
+// when there are no trials. A conditional probability P(A|B) is a
+// proportion among just the reads where B is 1, so its interval uses their
+// number as n rather than the number of reads.
+func wilsonInterval(k, n int, level float64) (lo, hi float64) {
+	if n == 0 {
+		return math.NaN(), math.NaN()
+	}
+	z := math.Sqrt2 * math.Erfinv(level)
+	p := float64(k) / float64(n)
+	nf := float64(n)
+	denom := 1 + z*z/nf
+	center := (p + z*z/(2*nf)) / denom
+	half := z * math.Sqrt(p*(1-p)/nf+z*z/(4*nf*nf)) / denom
+	return math.Max(0, center-half), math.Min(1, center+half)
+}
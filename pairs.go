@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// readPairs reads a two-column TSV listing the pairs of columns whose joints
+// and conditionals should be computed, returning the pairs as column
+// indices.
+func readPairs(path string, fieldNames []string) ([][2]int, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pairs file '%s': %v", path, err)
+	}
+	defer fp.Close()
+	index := make(map[string]int)
+	for i, name := range fieldNames {
+		index[name] = i
+	}
+	var pairs [][2]int
+	scanner := bufio.NewScanner(fp)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("expected line %d of '%s' to have 2 fields", lineNum, path)
+		}
+		var pair [2]int
+		for k, name := range fields {
+			i, ok := index[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown column '%s' on line %d of '%s'", name, lineNum, path)
+			}
+			pair[k] = i
+		}
+		pairs = append(pairs, pair)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+// pairList returns the ordered pairs of columns that the joints and
+// conditionals are written for: the -pairs if given, or else every pair,
+// with the pairs below the diagonal left out when triangle is set.
+func (t *Tally) pairList(triangle bool) [][2]int {
+	if t.Pairs != nil {
+		return t.Pairs
+	}
+	var pairs [][2]int
+	for i := range t.FieldNames {
+		for j := range t.FieldNames {
+			if triangle && j < i {
+				continue
+			}
+			pairs = append(pairs, [2]int{i, j})
+		}
+	}
+	return pairs
+}
@@ -0,0 +1,195 @@
+package main
+
+import "io"
+
+// parallelBatchSize is the number of lines handed to a parsing goroutine at
+// a time.
+const parallelBatchSize = 1024
+
+// A lineBatch is a run of consecutive lines of input, copied out of the
+// scanner, and their parsed values once done is closed.
+type lineBatch struct {
+	firstLine int
+	data      []byte
+	// ends[k] is the offset in data of the end of line k
+	ends    []int
+	reads   []string
+	weights []float64
+	groups  []string
+	// rows holds the values of every line, one row after another
+	rows []int
+	// lineErrs holds the parse error of each line that failed, if any did
+	// and -strict=false or -skip-bad lets them be skipped
+	lineErrs []error
+	// err is the error that ended the batch, after its lines
+	err  error
+	done chan struct{}
+}
+
+// parallelWideReader parses wide format input with a pool of goroutines. One
+// goroutine reads batches of lines while the others parse them, and the
+// batches are served in the order they were read, so the rows come out in
+// exactly the same order as from a wideReader.
+type parallelWideReader struct {
+	fieldNames []string
+	layout     *wideLayout
+	batches    chan *lineBatch
+	quit       chan struct{}
+	batch      *lineBatch
+	pos        int
+	err        error
+}
+
+func newParallelWideReader(r io.Reader, limit, threads int) (rowReader, error) {
+	wr, err := newWideReader(r, limit)
+	if err != nil || wr.fieldNames == nil {
+		// There are no lines to parse
+		return wr, err
+	}
+	pr := &parallelWideReader{
+		fieldNames: wr.fieldNames,
+		layout:     &wr.layout,
+		batches:    make(chan *lineBatch, 2*threads),
+		quit:       make(chan struct{}),
+	}
+	work := make(chan *lineBatch, threads)
+	go pr.readBatches(wr, work)
+	for k := 0; k < threads; k++ {
+		go pr.parseBatches(work)
+	}
+	return pr, nil
+}
+
+// readBatches reads the lines of input after the header in batches, queuing
+// each one both to be parsed and to be served.
+func (pr *parallelWideReader) readBatches(wr *wideReader, work chan<- *lineBatch) {
+	defer close(work)
+	defer close(pr.batches)
+	for more := true; more; {
+		b := &lineBatch{firstLine: wr.lineNum + 1, done: make(chan struct{})}
+		for len(b.ends) < parallelBatchSize {
+			if wr.limit > 0 && wr.lineNum > wr.limit {
+				more = false
+				break
+			}
+			if !wr.scanner.Scan() {
+				b.err = wr.scanner.Err()
+				more = false
+				break
+			}
+			wr.lineNum++
+			b.data = append(b.data, wr.scanner.Bytes()...)
+			b.ends = append(b.ends, len(b.data))
+		}
+		if len(b.ends) == 0 && b.err == nil {
+			return
+		}
+		select {
+		case pr.batches <- b:
+		case <-pr.quit:
+			return
+		}
+		select {
+		case work <- b:
+		case <-pr.quit:
+			return
+		}
+	}
+}
+
+// parseBatches parses the lines of each batch it is given. A batch is cut
+// short at the first line that doesn't parse.
+func (pr *parallelWideReader) parseBatches(work <-chan *lineBatch) {
+	numFields := len(pr.fieldNames)
+	var fields [][]byte
+	for b := range work {
+		b.rows = make([]int, len(b.ends)*numFields)
+		b.reads = make([]string, len(b.ends))
+		b.weights = make([]float64, len(b.ends))
+		if pr.layout.groupCol >= 0 {
+			b.groups = make([]string, len(b.ends))
+		}
+		start := 0
+		for k, end := range b.ends {
+			var err error
+			fields, b.weights[k], err = parseWideLine(b.data[start:end], fields, b.rows[k*numFields:(k+1)*numFields], pr.layout, b.firstLine+k)
+			if err != nil && badRows.skipping() {
+				if b.lineErrs == nil {
+					b.lineErrs = make([]error, len(b.ends))
+				}
+				b.lineErrs[k] = err
+				start = end
+				continue
+			}
+			if err != nil {
+				b.ends = b.ends[:k]
+				b.err = err
+				break
+			}
+			b.reads[k] = string(fields[0])
+			if b.groups != nil {
+				b.groups[k] = string(fields[pr.layout.groupCol+1])
+			}
+			start = end
+		}
+		close(b.done)
+	}
+}
+
+func (pr *parallelWideReader) Fields() []string {
+	return pr.fieldNames
+}
+
+func (pr *parallelWideReader) Scan() bool {
+	if pr.err != nil {
+		return false
+	}
+	for {
+		for pr.batch == nil || pr.pos+1 >= len(pr.batch.ends) {
+			if pr.batch != nil && pr.batch.err != nil {
+				pr.err = pr.batch.err
+				close(pr.quit)
+				return false
+			}
+			b, ok := <-pr.batches
+			if !ok {
+				return false
+			}
+			<-b.done
+			pr.batch, pr.pos = b, -1
+		}
+		pr.pos++
+		b := pr.batch
+		if b.lineErrs == nil || b.lineErrs[pr.pos] == nil {
+			return true
+		}
+		// The bad lines are recorded here rather than by the parsing
+		// goroutines so that they are recorded in order
+		badRows.tolerate(b.lineErrs[pr.pos])
+	}
+}
+
+func (pr *parallelWideReader) Read() string {
+	return pr.batch.reads[pr.pos]
+}
+
+func (pr *parallelWideReader) Row() []int {
+	numFields := len(pr.fieldNames)
+	return pr.batch.rows[pr.pos*numFields : (pr.pos+1)*numFields]
+}
+
+func (pr *parallelWideReader) Weight() float64 {
+	return pr.batch.weights[pr.pos]
+}
+
+func (pr *parallelWideReader) MissingCounts() []int64 {
+	return pr.layout.missing
+}
+
+func (pr *parallelWideReader) Group() string {
+	return pr.batch.groups[pr.pos]
+}
+
+func (pr *parallelWideReader) Err() error {
+	return pr.err
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// permuteColumn returns a copy of a column with its values shuffled across
+// the reads.
+func permuteColumn(col bitset, numReads int) bitset {
+	shuffled := make(bitset, len(col))
+	for k, from := range random.Perm(numReads) {
+		if col.get(from) {
+			shuffled.set(k)
+		}
+	}
+	return shuffled
+}
+
+// permutationPValue estimates how often shuffling column j across the reads
+// gives a joint count with column i at least as far from its expected value
+// as the observed one. Shuffling keeps both marginals, so this is a two-sided
+// test of the phi correlation as well. The count includes the observed table
+// itself, so the p-value is never zero.
+func (t *Tally) permutationPValue(i, j, permutations int) float64 {
+	expected := float64(t.Marginals[i]) * float64(t.Marginals[j]) / float64(t.NumReads)
+	observed := math.Abs(float64(andCount(t.Columns[i], t.Columns[j])) - expected)
+	extreme := 1
+	for b := 0; b < permutations; b++ {
+		joint := andCount(t.Columns[i], permuteColumn(t.Columns[j], t.NumReads))
+		// Allow for rounding in the expected count, so that tables as
+		// extreme as the observed one always count
+		if math.Abs(float64(joint)-expected) >= observed-1e-9 {
+			extreme++
+		}
+	}
+	return float64(extreme) / float64(permutations+1)
+}
+
+// writePermutation prints the phi correlation of every unordered pair of
+// indicators, or of each of the -pairs, with the p-value of a -permute
+// permutation test of their association. Every permutation re-tallies the
+// pair over all the reads, so this takes time proportional to the number of
+// pairs times -permute times the number of reads; use -pairs to test only
+// the pairs of interest.
+func writePermutation(w io.Writer, t *Tally, names []string) {
+	for _, pair := range t.pairList(true) {
+		i, j := pair[0], pair[1]
+		if i == j {
+			continue
+		}
+		n11 := andCount(t.Columns[i], t.Columns[j])
+		n10 := t.Marginals[i] - n11
+		n01 := t.Marginals[j] - n11
+		n00 := t.NumReads - n11 - n10 - n01
+		fmt.Fprintf(w, "permutation( %s , %s ) = %s ; %s , %d\n", names[i], names[j],
+			formatValue(t.permutationPValue(i, j, args.Permute), 'g', 8), formatValue(mcc(n11, n10, n01, n00), 'f', 8), args.Permute)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The distances that -phylip can write.
+var phylipDistances = []string{"jaccard", "hamming"}
+
+func validPhylipDistance(kind string) bool {
+	for _, k := range phylipDistances {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// phylipNameWidth is the fixed width of the names in a PHYLIP file.
+const phylipNameWidth = 10
+
+// phylipNames fits the names to PHYLIP's fixed width: longer names are cut
+// to their first 10 characters and shorter ones padded with spaces. Where
+// cutting leaves two names the same, each of them has its end replaced by
+// its column's number, counting from 1, so that every name stays distinct.
+func phylipNames(names []string) []string {
+	cut := make([]string, len(names))
+	count := make(map[string]int)
+	for i, name := range names {
+		if len(name) > phylipNameWidth {
+			name = name[:phylipNameWidth]
+		}
+		cut[i] = name
+		count[name]++
+	}
+	for i, name := range cut {
+		if count[name] > 1 && len(names[i]) > phylipNameWidth {
+			number := strconv.Itoa(i + 1)
+			name = name[:phylipNameWidth-len(number)] + number
+		}
+		cut[i] = name + strings.Repeat(" ", phylipNameWidth-len(name))
+	}
+	return cut
+}
+
+// writePhylip prints the distance between every pair of columns as a
+// square PHYLIP distance matrix: a line giving the number of columns, then
+// a line for each column of its name, fitted by phylipNames, and its
+// distances. The -phylip-distance is 1 - Jaccard, as for -jaccard-distances,
+// or the Hamming distance as the fraction of reads in which the columns
+// differ, which is 0 for every pair when there are no reads.
+func writePhylip(w io.Writer, t *Tally, names []string) {
+	var dist [][]float64
+	if args.PhylipDistance == "hamming" {
+		dist = make([][]float64, len(names))
+		for i := range dist {
+			dist[i] = make([]float64, len(names))
+			for j := range dist[i] {
+				_, n10, n01, _ := t.Contingency(i, j)
+				if t.NumReads > 0 {
+					dist[i][j] = float64(n10+n01) / float64(t.NumReads)
+				}
+			}
+		}
+	} else {
+		dist = columnDistances(t, "jaccard")
+	}
+	fmt.Fprintf(w, "%5d\n", len(names))
+	for i, name := range phylipNames(names) {
+		fmt.Fprint(w, name)
+		for j := range names {
+			fmt.Fprintf(w, " %s", strconv.FormatFloat(dist[i][j], 'f', 6, 64))
+		}
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// The -pretty tables are meant for reading in a terminal. Every cell is
+// right-aligned by the tabwriter, so names are padded to a common width
+// first to make them line up on the left instead.
+
+func newTableWriter(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+}
+
+// padNames pads the names with spaces to the width of the longest one.
+func padNames(names []string) []string {
+	width := 0
+	for _, name := range names {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	padded := make([]string, len(names))
+	for i, name := range names {
+		padded[i] = name + strings.Repeat(" ", width-len(name))
+	}
+	return padded
+}
+
+func writeMarginalsTable(w io.Writer, t *Tally, names []string) {
+	tw := newTableWriter(w)
+	padded := padNames(append([]string{"column"}, names...))
+	fmt.Fprintf(tw, "%s\tP\tcount\t\n", padded[0])
+	for i, name := range padded[1:] {
+		mar := float64(t.Marginals[i]) / float64(t.NumReads)
+		fmt.Fprintf(tw, "%s\t%s\t%d\t\n", name, formatProb(mar, 6), t.Marginals[i])
+	}
+	tw.Flush()
+}
+
+func writeJointsTable(w io.Writer, t *Tally, names []string) {
+	tw := newTableWriter(w)
+	padded := padNames(append([]string{"A", "B"}, names...))
+	seHeader := ""
+	if args.JointSE {
+		seHeader = "SE\t"
+	}
+	fmt.Fprintf(tw, "%s\t%s\tP(A,B)\tn(A,B)\t%s\n", padded[0], padded[1], seHeader)
+	names = padded[2:]
+	for _, pair := range t.jointPairs() {
+		i, j := pair[0], pair[1]
+		iName, jName := names[i], names[j]
+		jointProb := float64(t.Joints[i][j]) / float64(t.NumReads)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t", iName, jName, formatProb(jointProb, 8), t.Joints[i][j])
+		if args.JointSE {
+			fmt.Fprintf(tw, "%s\t", formatProb(binomialSE(jointProb, t.NumReads), 8))
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
+
+func writeConditionalsTable(w io.Writer, t *Tally, names []string) {
+	tw := newTableWriter(w)
+	padded := padNames(append([]string{"A", "B"}, names...))
+	ciHeader := ""
+	if args.ConditionalCI {
+		ciHeader = "lower\tupper\t"
+	}
+	fmt.Fprintf(tw, "%s\t%s\tP(A|B)\tn(A,B)\tn(B)\t%s\n", padded[0], padded[1], ciHeader)
+	names = padded[2:]
+	for _, pair := range t.conditionalPairs() {
+		i, j := pair[0], pair[1]
+		iName, jName := names[i], names[j]
+		condProb := float64(t.Joints[i][j]) / float64(t.Marginals[i])
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t", jName, iName, formatProb(condProb, 8), t.Joints[i][j], t.Marginals[i])
+		if args.ConditionalCI {
+			lo, hi := wilsonInterval(t.Joints[i][j], t.Marginals[i], args.CILevel)
+			fmt.Fprintf(tw, "%s\t%s\t", formatProb(lo, 8), formatProb(hi, 8))
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+}
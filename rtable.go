@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The -rtable format is rectangular, tab-separated data with a header row
+// of syntactic names, so it can be loaded in R with read.table(header=TRUE).
+// Column names are quoted in case they contain spaces, and undefined values
+// are written as NA. Values are always plain fixed-point numbers, to
+// -precision decimal places, whatever -percent or -sci say, so that R
+// reads them as numeric columns.
+
+func rQuote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `\"`) + `"`
+}
+
+func rValue(x float64, decimals int) string {
+	if math.IsNaN(x) {
+		return "NA"
+	}
+	if args.Precision > 0 {
+		decimals = args.Precision
+	}
+	return strconv.FormatFloat(x, 'f', decimals, 64)
+}
+
+func writeMarginalsRTable(w io.Writer, t *Tally, names []string) {
+	fmt.Fprintln(w, "column\tprobability\tcount")
+	for i, name := range names {
+		mar := float64(t.Marginals[i]) / float64(t.NumReads)
+		fmt.Fprintf(w, "%s\t%s\t%d\n", rQuote(name), rValue(mar, 6), t.Marginals[i])
+	}
+}
+
+func writeJointsRTable(w io.Writer, t *Tally, names []string) {
+	if args.JointSE {
+		fmt.Fprintln(w, "column_a\tcolumn_b\tprobability\tcount\tse")
+	} else {
+		fmt.Fprintln(w, "column_a\tcolumn_b\tprobability\tcount")
+	}
+	for _, pair := range t.jointPairs() {
+		i, j := pair[0], pair[1]
+		iName, jName := names[i], names[j]
+		jointProb := float64(t.Joints[i][j]) / float64(t.NumReads)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d", rQuote(iName), rQuote(jName), rValue(jointProb, 8), t.Joints[i][j])
+		if args.JointSE {
+			fmt.Fprintf(w, "\t%s", rValue(binomialSE(jointProb, t.NumReads), 8))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func writeConditionalsRTable(w io.Writer, t *Tally, names []string) {
+	if args.ConditionalCI {
+		fmt.Fprintln(w, "column\tgiven\tprobability\tjoint_count\tgiven_count\tlower\tupper")
+	} else {
+		fmt.Fprintln(w, "column\tgiven\tprobability\tjoint_count\tgiven_count")
+	}
+	for _, pair := range t.conditionalPairs() {
+		i, j := pair[0], pair[1]
+		iName, jName := names[i], names[j]
+		condProb := float64(t.Joints[i][j]) / float64(t.Marginals[i])
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d",
+			rQuote(jName), rQuote(iName), rValue(condProb, 8), t.Joints[i][j], t.Marginals[i])
+		if args.ConditionalCI {
+			lo, hi := wilsonInterval(t.Joints[i][j], t.Marginals[i], args.CILevel)
+			fmt.Fprintf(w, "\t%s\t%s", rValue(lo, 8), rValue(hi, 8))
+		}
+		fmt.Fprintln(w)
+	}
+}
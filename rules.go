@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ruleSorts are the orders -rules-sort can list the rules in.
+var ruleSorts = []string{"confidence", "lift"}
+
+// A rule is an association rule A => B between two columns.
+type rule struct {
+	antecedent, consequent int
+	count                  int
+	support                float64
+	confidence             float64
+	lift                   float64
+}
+
+// associationRules returns every rule A => B between distinct columns whose
+// joint count is at least -minsupport and whose confidence P(B|A) is at
+// least -minconf, sorted by -rules-sort from the strongest down, keeping
+// only the first -top-rules if given. Ties keep the column order.
+func associationRules(t *Tally) []rule {
+	var rules []rule
+	n := float64(t.NumReads)
+	for i := range t.FieldNames {
+		for j := range t.FieldNames {
+			if i == j || t.Marginals[i] == 0 || t.Joints[i][j] < args.MinSupport {
+				continue
+			}
+			r := rule{antecedent: i, consequent: j, count: t.Joints[i][j]}
+			r.support = float64(r.count) / n
+			r.confidence = float64(r.count) / float64(t.Marginals[i])
+			if r.confidence < args.MinConf {
+				continue
+			}
+			// lift = P(A,B) / (P(A) P(B)) = P(B|A) / P(B)
+			r.lift = r.confidence / (float64(t.Marginals[j]) / n)
+			rules = append(rules, r)
+		}
+	}
+	sort.SliceStable(rules, func(a, b int) bool {
+		if args.RulesSort == "lift" {
+			return rules[a].lift > rules[b].lift
+		}
+		return rules[a].confidence > rules[b].confidence
+	})
+	if args.TopRules > 0 && len(rules) > args.TopRules {
+		rules = rules[:args.TopRules]
+	}
+	return rules
+}
+
+// writeRules prints the association rules between pairs of columns with
+// their support P(A,B), confidence P(B|A) and lift.
+func writeRules(w io.Writer, t *Tally, names []string) {
+	fmt.Fprintln(w, "antecedent\tconsequent\tsupport\tconfidence\tlift\tcount")
+	for _, r := range associationRules(t) {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\n", names[r.antecedent], names[r.consequent],
+			formatProb(r.support, 8), formatProb(r.confidence, 8), formatValue(r.lift, 'f', 8), r.count)
+	}
+}
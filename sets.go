@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// resolveColumns looks up a comma-separated list of column names, returning
+// their indices. An empty list means every column.
+func resolveColumns(list string, fieldNames []string) ([]int, error) {
+	if list == "" {
+		cols := make([]int, len(fieldNames))
+		for i := range cols {
+			cols[i] = i
+		}
+		return cols, nil
+	}
+	index := make(map[string]int)
+	for i, name := range fieldNames {
+		index[name] = i
+	}
+	var cols []int
+	for _, name := range strings.Split(list, ",") {
+		i, ok := index[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown column '%s'", name)
+		}
+		cols = append(cols, i)
+	}
+	return cols, nil
+}
+
+// resolveJointColumns returns the indices of the -joint-columns in the
+// order of the columns, rather than of the list.
+func resolveJointColumns(list string, fieldNames []string) ([]int, error) {
+	cols, err := resolveColumns(list, fieldNames)
+	if err != nil {
+		return nil, err
+	}
+	sort.Ints(cols)
+	for k := 1; k < len(cols); k++ {
+		if cols[k] == cols[k-1] {
+			return nil, fmt.Errorf("column '%s' is listed more than once", fieldNames[cols[k]])
+		}
+	}
+	return cols, nil
+}
+
+// setTally counts the reads that are 1 in at least one, and in all, of a
+// set of columns. Every read is 1 in all of an empty set. A set tracked for
+// -given also counts, for each column, the reads that are 1 in it as well
+// as in all of the set.
+type setTally struct {
+	cols  []int
+	any   int
+	all   int
+	given []int
+}
+
+func (st *setTally) add(row []int, times int) {
+	ones := 0
+	for _, i := range st.cols {
+		ones += row[i]
+	}
+	if ones > 0 {
+		st.any += times
+	}
+	if ones == len(st.cols) {
+		st.all += times
+		for i := range st.given {
+			st.given[i] += row[i] * times
+		}
+	}
+}
+
+func setNames(cols []int, names []string, sep string) string {
+	parts := make([]string, len(cols))
+	for k, i := range cols {
+		parts[k] = names[i]
+	}
+	return strings.Join(parts, sep)
+}
+
+// writeGivenConditionals prints the probability of each column other than
+// the -given columns among the reads that are 1 in all of them, followed by
+// the number of reads that are 1 in the column and all of the -given
+// columns, and the number that are 1 in all of the -given columns. The
+// probability is undefined if no read is 1 in all of them.
+func writeGivenConditionals(w io.Writer, t *Tally, names []string) {
+	given := setNames(t.GivenSet.cols, names, " and ")
+	in := make(map[int]bool)
+	for _, i := range t.GivenSet.cols {
+		in[i] = true
+	}
+	for i, name := range names {
+		if in[i] {
+			continue
+		}
+		p := args.NaToken
+		if t.GivenSet.all > 0 {
+			p = formatProb(float64(t.GivenSet.given[i])/float64(t.GivenSet.all), 8)
+		}
+		fmt.Fprintf(w, "P( %s | %s ) = %s ; %d , %d\n", name, given, p, t.GivenSet.given[i], t.GivenSet.all)
+	}
+}
+
+// writeAny prints the probability that a read is 1 in any of the -any-of
+// columns, and the number of such reads.
+func writeAny(w io.Writer, t *Tally, names []string) {
+	p := float64(t.AnySet.any) / float64(t.NumReads)
+	fmt.Fprintf(w, "P( %s ) = %s ; %d\n", setNames(t.AnySet.cols, names, " or "), formatProb(p, 6), t.AnySet.any)
+}
+
+// writeAll prints the probability that a read is 1 in all of the -all-of
+// columns, and the number of such reads.
+func writeAll(w io.Writer, t *Tally, names []string) {
+	p := float64(t.AllSet.all) / float64(t.NumReads)
+	desc := setNames(t.AllSet.cols, names, " and ")
+	if len(t.AllSet.cols) == 0 {
+		desc = "all of none"
+	}
+	fmt.Fprintf(w, "P( %s ) = %s ; %d\n", desc, formatProb(p, 6), t.AllSet.all)
+}
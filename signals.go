@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// errInterrupted is returned when SIGINT or SIGTERM stops the reading of
+// the input early.
+var errInterrupted = errors.New("interrupted")
+
+// interruptContext returns a context that is cancelled by the first SIGINT
+// or SIGTERM. The signals' default behavior is restored after that, so a
+// second one exits at once, even while reading is blocked on the input.
+func interruptContext() context.Context {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx
+}
+
+// removeOutputs removes the output files after an interruption, since they
+// would be missing results. Outputs that aren't regular files, such as
+// /dev/stdout, are left alone, as are the outputs of -flush-interval and
+// -flush-every, which are replaced whole and so always hold a complete
+// snapshot.
+func removeOutputs() {
+	if args.FlushInterval > 0 {
+		return
+	}
+	for _, out := range selectedOutputs() {
+		if out.flushedEvery() {
+			continue
+		}
+		if fi, err := os.Stat(*out.path); err == nil && fi.Mode().IsRegular() {
+			os.Remove(*out.path)
+		}
+	}
+}
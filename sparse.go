@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// sparseReader parses positives-only input. After a 'read\tcolumn' header,
+// each line names a read and one of its columns that is 1; every cell that
+// is not listed is 0. The full set of columns comes from a separate file
+// with one column name per line. A read with no positive columns at all can
+// be listed with an empty column field so that it still counts toward the
+// number of reads.
+//
+// Lines must be grouped by read so the matrix can be streamed one read at a
+// time without ever being materialized.
+type sparseReader struct {
+	scanner    *bufio.Scanner
+	limit      int
+	lineNum    int
+	fieldNames []string
+	colIndex   map[string]int
+	seenReads  map[string]bool
+	// the first line of the next read, which has already been scanned
+	pending []string
+	read    string
+	row     []int
+	set     []int
+	err     error
+}
+
+func newSparseReader(r io.Reader, columnsPath string, limit int) (*sparseReader, error) {
+	fieldNames, err := readColumnList(columnsPath)
+	if err != nil {
+		return nil, err
+	}
+	sr := &sparseReader{
+		scanner:    bufio.NewScanner(r),
+		limit:      limit,
+		fieldNames: fieldNames,
+		colIndex:   make(map[string]int),
+		seenReads:  make(map[string]bool),
+		row:        make([]int, len(fieldNames)),
+	}
+	for i, name := range fieldNames {
+		if _, ok := sr.colIndex[name]; ok {
+			return nil, fmt.Errorf("column '%s' is listed more than once in '%s'", name, columnsPath)
+		}
+		sr.colIndex[name] = i
+	}
+	if !sr.scanner.Scan() {
+		return sr, sr.scanner.Err()
+	}
+	sr.lineNum++
+	// This should be the header line
+	fields := strings.Split(sr.scanner.Text(), "\t")
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("sparse format header should have 2 fields")
+	}
+	if fields[0] != "read" {
+		return nil, fmt.Errorf("first field should be named 'read'")
+	}
+	sr.pending, sr.err = sr.nextLine()
+	return sr, sr.err
+}
+
+// readColumnList reads a file giving one column name per line.
+func readColumnList(path string) ([]string, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open column list '%s': %v", path, err)
+	}
+	defer fp.Close()
+	var names []string
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		name := columnName(strings.TrimSpace(scanner.Text()))
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("column list '%s' is empty", path)
+	}
+	return names, nil
+}
+
+// nextLine returns the fields of the next line of input, or nil at the end.
+func (sr *sparseReader) nextLine() ([]string, error) {
+	if sr.limit > 0 && sr.lineNum > sr.limit {
+		return nil, nil
+	}
+	if !sr.scanner.Scan() {
+		return nil, sr.scanner.Err()
+	}
+	sr.lineNum++
+	fields := strings.Split(sr.scanner.Text(), "\t")
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("expected line %d to have 2 fields", sr.lineNum)
+	}
+	return fields, nil
+}
+
+func (sr *sparseReader) Fields() []string {
+	return sr.fieldNames
+}
+
+func (sr *sparseReader) Scan() bool {
+	if sr.err != nil || sr.pending == nil {
+		return false
+	}
+	for _, i := range sr.set {
+		sr.row[i] = 0
+	}
+	sr.set = sr.set[:0]
+	sr.read = sr.pending[0]
+	if sr.seenReads[sr.read] {
+		sr.err = fmt.Errorf("read '%s' on line %d was already seen; sparse input must be grouped by read",
+			sr.read, sr.lineNum)
+		return false
+	}
+	sr.seenReads[sr.read] = true
+	for sr.pending != nil && sr.pending[0] == sr.read {
+		if col := columnName(sr.pending[1]); col != "" {
+			i, ok := sr.colIndex[col]
+			if !ok {
+				sr.err = fmt.Errorf("unknown column '%s' on line %d", col, sr.lineNum)
+				return false
+			}
+			if sr.row[i] == 0 {
+				sr.row[i] = 1
+				sr.set = append(sr.set, i)
+			}
+		}
+		sr.pending, sr.err = sr.nextLine()
+		if sr.err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (sr *sparseReader) Read() string {
+	return sr.read
+}
+
+func (sr *sparseReader) Row() []int {
+	return sr.row
+}
+
+func (sr *sparseReader) Err() error {
+	return sr.err
+}
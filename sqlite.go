@@ -0,0 +1,521 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// There is no SQLite driver available to this build, so the -sqlite output
+// is written directly in the SQLite file format, as described at
+// https://www.sqlite.org/fileformat.html. Each table is written once, in
+// rowid order, as a B-tree packed from the leaves up, and each index is
+// built by sorting the entries of its table. The whole database is built in
+// memory before it is written out.
+
+const (
+	sqlitePageSize = 4096
+	// the most payload a cell of a table leaf, or of an index, keeps on its
+	// page, and the least it keeps when the rest spills onto overflow pages
+	sqliteTableMaxLocal = sqlitePageSize - 35
+	sqliteIndexMaxLocal = (sqlitePageSize-12)*64/255 - 23
+	sqliteMinLocal      = (sqlitePageSize-12)*32/255 - 23
+)
+
+// The kinds of B-tree page.
+const (
+	sqliteIndexInterior = 0x02
+	sqliteTableInterior = 0x05
+	sqliteIndexLeaf     = 0x0a
+	sqliteTableLeaf     = 0x0d
+)
+
+// A sqliteTable is a table and the indexes on it. The values of its rows
+// are nil, int, float64 or string, and the rowid of each is its position,
+// counting from 1. A column declared INTEGER PRIMARY KEY is the rowid, and
+// is stored as nil.
+type sqliteTable struct {
+	name    string
+	sql     string
+	rows    [][]interface{}
+	indexes []sqliteIndex
+}
+
+// A sqliteIndex is an index on the values of some columns of a table.
+type sqliteIndex struct {
+	name string
+	sql  string
+	cols []int
+}
+
+// sqliteTables returns the tables the -sqlite database holds: the number of
+// reads and columns of the run, the marginal of each column, the joint and
+// conditional probability, P(b | a), of each pair of columns keyed by their
+// ids, and the input value of every read in each column.
+func sqliteTables(t *Tally, names []string) []sqliteTable {
+	n := float64(t.NumReads)
+	runs := sqliteTable{
+		name: "runs",
+		sql:  "CREATE TABLE runs (num_reads INTEGER NOT NULL, num_columns INTEGER NOT NULL)",
+		rows: [][]interface{}{{t.NumReads, len(names)}},
+	}
+	columns := sqliteTable{
+		name: "columns",
+		sql:  "CREATE TABLE columns (id INTEGER PRIMARY KEY, name TEXT NOT NULL, count INTEGER NOT NULL, marginal REAL)",
+		indexes: []sqliteIndex{
+			{"columns_name", "CREATE INDEX columns_name ON columns (name)", []int{1}},
+		},
+	}
+	for i, name := range names {
+		columns.rows = append(columns.rows, []interface{}{nil, name, t.Marginals[i], float64(t.Marginals[i]) / n})
+	}
+	pairs := sqliteTable{
+		name: "pairs",
+		sql: "CREATE TABLE pairs (a INTEGER NOT NULL REFERENCES columns(id), b INTEGER NOT NULL REFERENCES columns(id)," +
+			" joint_count INTEGER NOT NULL, joint REAL, conditional REAL)",
+		indexes: []sqliteIndex{
+			{"pairs_a_b", "CREATE UNIQUE INDEX pairs_a_b ON pairs (a, b)", []int{0, 1}},
+			{"pairs_b", "CREATE INDEX pairs_b ON pairs (b)", []int{1}},
+		},
+	}
+	for i := range names {
+		for j := range names {
+			joint := t.Joints[i][j]
+			pairs.rows = append(pairs.rows, []interface{}{i + 1, j + 1, joint,
+				float64(joint) / n, float64(joint) / float64(t.Marginals[i])})
+		}
+	}
+	values := sqliteTable{
+		name: "values",
+		sql:  `CREATE TABLE "values" (read TEXT NOT NULL, column_id INTEGER NOT NULL REFERENCES columns(id), value INTEGER NOT NULL)`,
+		indexes: []sqliteIndex{
+			{"values_column_id", `CREATE INDEX values_column_id ON "values" (column_id)`, []int{1}},
+		},
+	}
+	for r, read := range t.Reads {
+		for i := range names {
+			value := 0
+			if t.Columns[i].get(r) {
+				value = 1
+			}
+			values.rows = append(values.rows, []interface{}{read, i + 1, value})
+		}
+	}
+	return []sqliteTable{runs, columns, pairs, values}
+}
+
+// writeSQLite writes a SQLite database of the sqliteTables. Undefined
+// values are stored as NULL. The values table is named with a keyword, so
+// it has to be quoted in queries, as in SELECT * FROM "values".
+func writeSQLite(w io.Writer, t *Tally, names []string) {
+	db := &sqliteDB{pages: [][]byte{make([]byte, sqlitePageSize)}}
+	var schema [][]interface{}
+	for _, table := range sqliteTables(t, names) {
+		schema = append(schema, []interface{}{"table", table.name, table.name, db.writeTable(table.rows), table.sql})
+		for _, index := range table.indexes {
+			schema = append(schema, []interface{}{"index", index.name, table.name, db.writeIndex(table.rows, index.cols), index.sql})
+		}
+	}
+	var cells [][]byte
+	for k, row := range schema {
+		cells = append(cells, db.tableCell(int64(k+1), sqliteRecord(row)))
+	}
+	if !fitsPage(100, 8, cells) {
+		panic("the sqlite schema doesn't fit on the first page")
+	}
+	writeBTreePage(db.pages[0], 100, sqliteTableLeaf, cells, 0)
+	db.writeHeader()
+	for _, page := range db.pages {
+		w.Write(page)
+	}
+}
+
+// sqliteDB holds the pages of a database as they are written, the first
+// of which is page number 1.
+type sqliteDB struct {
+	pages [][]byte
+}
+
+// allocate adds a page to the database, returning its number.
+func (db *sqliteDB) allocate() (int, []byte) {
+	page := make([]byte, sqlitePageSize)
+	db.pages = append(db.pages, page)
+	return len(db.pages), page
+}
+
+// writeHeader fills in the database header at the start of the first page,
+// for a database in the legacy rollback journal mode with UTF-8 text and no
+// free pages.
+func (db *sqliteDB) writeHeader() {
+	h := db.pages[0][:100]
+	copy(h, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(h[16:], sqlitePageSize)
+	h[18], h[19] = 1, 1
+	h[21], h[22], h[23] = 64, 32, 32
+	binary.BigEndian.PutUint32(h[24:], 1)
+	binary.BigEndian.PutUint32(h[28:], uint32(len(db.pages)))
+	binary.BigEndian.PutUint32(h[40:], 1)
+	binary.BigEndian.PutUint32(h[44:], 4)
+	binary.BigEndian.PutUint32(h[56:], 1)
+	binary.BigEndian.PutUint32(h[92:], 1)
+	binary.BigEndian.PutUint32(h[96:], 3008000)
+}
+
+// sqliteRecord encodes values in SQLite's record format.
+func sqliteRecord(values []interface{}) []byte {
+	var header, body []byte
+	for _, v := range values {
+		switch v := v.(type) {
+		case int:
+			serial, size := sqliteIntType(int64(v))
+			header = putVarint(header, serial)
+			for k := size - 1; k >= 0; k-- {
+				body = append(body, byte(int64(v)>>(8*uint(k))))
+			}
+		case float64:
+			if math.IsNaN(v) {
+				header = putVarint(header, 0)
+				continue
+			}
+			header = putVarint(header, 7)
+			body = binary.BigEndian.AppendUint64(body, math.Float64bits(v))
+		case string:
+			header = putVarint(header, uint64(2*len(v)+13))
+			body = append(body, v...)
+		default:
+			header = putVarint(header, 0)
+		}
+	}
+	// the size of the header counts the varint giving it
+	size := len(header) + 1
+	for len(header)+varintLen(uint64(size)) != size {
+		size = len(header) + varintLen(uint64(size))
+	}
+	record := putVarint(nil, uint64(size))
+	record = append(record, header...)
+	return append(record, body...)
+}
+
+// sqliteIntType returns the serial type of the smallest encoding of an
+// integer, and the number of bytes it takes.
+func sqliteIntType(v int64) (uint64, int) {
+	switch {
+	case v == 0:
+		return 8, 0
+	case v == 1:
+		return 9, 0
+	case v >= math.MinInt8 && v <= math.MaxInt8:
+		return 1, 1
+	case v >= math.MinInt16 && v <= math.MaxInt16:
+		return 2, 2
+	case v >= -1<<23 && v < 1<<23:
+		return 3, 3
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		return 4, 4
+	case v >= -1<<47 && v < 1<<47:
+		return 5, 6
+	}
+	return 6, 8
+}
+
+// putVarint appends SQLite's big-endian variable-length encoding of v,
+// which takes up to 9 bytes, the last of them holding 8 bits.
+func putVarint(buf []byte, v uint64) []byte {
+	if v > 1<<56-1 {
+		var b [9]byte
+		b[8] = byte(v)
+		v >>= 8
+		for k := 7; k >= 0; k-- {
+			b[k] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+		return append(buf, b[:]...)
+	}
+	var b [8]byte
+	n := 0
+	for {
+		b[n] = byte(v & 0x7f)
+		n++
+		v >>= 7
+		if v == 0 {
+			break
+		}
+	}
+	for k := n - 1; k >= 0; k-- {
+		if k > 0 {
+			b[k] |= 0x80
+		}
+		buf = append(buf, b[k])
+	}
+	return buf
+}
+
+func varintLen(v uint64) int {
+	return len(putVarint(nil, v))
+}
+
+// spill returns the part of a payload a cell keeps on its page, followed by
+// the number of the first overflow page the rest is written to, if it is
+// longer than maxLocal.
+func (db *sqliteDB) spill(payload []byte, maxLocal int) []byte {
+	if len(payload) <= maxLocal {
+		return payload
+	}
+	local := sqliteMinLocal + (len(payload)-sqliteMinLocal)%(sqlitePageSize-4)
+	if local > maxLocal {
+		local = sqliteMinLocal
+	}
+	cell := append([]byte(nil), payload[:local]...)
+	rest := payload[local:]
+	first := 0
+	var next []byte
+	for len(rest) > 0 {
+		num, page := db.allocate()
+		if next == nil {
+			first = num
+		} else {
+			binary.BigEndian.PutUint32(next, uint32(num))
+		}
+		rest = rest[copy(page[4:], rest):]
+		next = page[:4]
+	}
+	return binary.BigEndian.AppendUint32(cell, uint32(first))
+}
+
+func (db *sqliteDB) tableCell(rowid int64, record []byte) []byte {
+	cell := putVarint(nil, uint64(len(record)))
+	cell = putVarint(cell, uint64(rowid))
+	return append(cell, db.spill(record, sqliteTableMaxLocal)...)
+}
+
+func (db *sqliteDB) indexCell(record []byte) []byte {
+	cell := putVarint(nil, uint64(len(record)))
+	return append(cell, db.spill(record, sqliteIndexMaxLocal)...)
+}
+
+// fitsPage reports whether cells fit on a page after a header of the given
+// size, which starts at offset.
+func fitsPage(offset, header int, cells [][]byte) bool {
+	used := offset + header
+	for _, cell := range cells {
+		used += len(cell) + 2
+	}
+	return used <= sqlitePageSize
+}
+
+// writeBTreePage lays out a B-tree page: the page header at offset, the
+// pointers to the cells and the cells themselves packed from the end of the
+// page. Interior pages also point to the child right of every cell.
+func writeBTreePage(page []byte, offset int, kind byte, cells [][]byte, right int) {
+	header := 8
+	if kind == sqliteIndexInterior || kind == sqliteTableInterior {
+		header = 12
+		binary.BigEndian.PutUint32(page[offset+8:], uint32(right))
+	}
+	page[offset] = kind
+	binary.BigEndian.PutUint16(page[offset+3:], uint16(len(cells)))
+	end := len(page)
+	for k, cell := range cells {
+		end -= len(cell)
+		copy(page[end:], cell)
+		binary.BigEndian.PutUint16(page[offset+header+2*k:], uint16(end))
+	}
+	binary.BigEndian.PutUint16(page[offset+5:], uint16(end))
+}
+
+// A sqliteChild is a page of a table B-tree and the largest rowid on it.
+type sqliteChild struct {
+	page int
+	key  int64
+}
+
+// writeTable writes a table B-tree of the rows, returning its root page.
+// The leaves are filled in order, then each level of interior pages above
+// them, until one page points to all of the level below.
+func (db *sqliteDB) writeTable(rows [][]interface{}) int {
+	var level []sqliteChild
+	var cells [][]byte
+	for r, row := range rows {
+		cell := db.tableCell(int64(r+1), sqliteRecord(row))
+		if len(cells) > 0 && !fitsPage(0, 8, append(cells, cell)) {
+			level = append(level, db.writeLeaf(sqliteTableLeaf, cells, int64(r)))
+			cells = nil
+		}
+		cells = append(cells, cell)
+	}
+	level = append(level, db.writeLeaf(sqliteTableLeaf, cells, int64(len(rows))))
+	for len(level) > 1 {
+		// every child but the last of each node has a cell
+		var nodes [][]sqliteChild
+		var node []sqliteChild
+		var nodeCells [][]byte
+		for _, child := range level {
+			if len(node) > 0 {
+				cell := tableInteriorCell(node[len(node)-1])
+				if !fitsPage(0, 12, append(nodeCells, cell)) {
+					nodes = append(nodes, node)
+					node, nodeCells = nil, nil
+				} else {
+					nodeCells = append(nodeCells, cell)
+				}
+			}
+			node = append(node, child)
+		}
+		// an interior page needs at least one cell
+		if len(node) == 1 && len(nodes) > 0 {
+			prev := nodes[len(nodes)-1]
+			node = []sqliteChild{prev[len(prev)-1], node[0]}
+			nodes[len(nodes)-1] = prev[:len(prev)-1]
+		}
+		nodes = append(nodes, node)
+		level = nil
+		for _, node := range nodes {
+			var cells [][]byte
+			for _, child := range node[:len(node)-1] {
+				cells = append(cells, tableInteriorCell(child))
+			}
+			num, page := db.allocate()
+			writeBTreePage(page, 0, sqliteTableInterior, cells, node[len(node)-1].page)
+			level = append(level, sqliteChild{num, node[len(node)-1].key})
+		}
+	}
+	return level[0].page
+}
+
+func tableInteriorCell(child sqliteChild) []byte {
+	cell := binary.BigEndian.AppendUint32(nil, uint32(child.page))
+	return putVarint(cell, uint64(child.key))
+}
+
+func (db *sqliteDB) writeLeaf(kind byte, cells [][]byte, key int64) sqliteChild {
+	num, page := db.allocate()
+	writeBTreePage(page, 0, kind, cells, 0)
+	return sqliteChild{num, key}
+}
+
+// writeIndex writes an index B-tree of the values of the columns of each
+// row followed by its rowid, returning its root page. Unlike a table, an
+// index keeps entries on its interior pages too, each one separating the
+// children either side of it.
+func (db *sqliteDB) writeIndex(rows [][]interface{}, cols []int) int {
+	keys := make([][]interface{}, len(rows))
+	for r, row := range rows {
+		for _, c := range cols {
+			keys[r] = append(keys[r], row[c])
+		}
+		keys[r] = append(keys[r], r+1)
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		return compareSQLiteKeys(keys[a], keys[b]) < 0
+	})
+	entries := make([][]byte, len(keys))
+	for k, key := range keys {
+		entries[k] = db.indexCell(sqliteRecord(key))
+	}
+
+	// fill the leaves, keeping the entry after each, which doesn't fit on
+	// it, to separate it from the next
+	var leaves [][][]byte
+	var seps [][]byte
+	var leaf [][]byte
+	for _, entry := range entries {
+		if len(leaf) > 0 && !fitsPage(0, 8, append(leaf, entry)) {
+			leaves = append(leaves, leaf)
+			seps = append(seps, entry)
+			leaf = nil
+			continue
+		}
+		leaf = append(leaf, entry)
+	}
+	if len(leaf) == 0 && len(leaves) > 0 {
+		prev := leaves[len(leaves)-1]
+		leaf = [][]byte{seps[len(seps)-1]}
+		seps[len(seps)-1] = prev[len(prev)-1]
+		leaves[len(leaves)-1] = prev[:len(prev)-1]
+	}
+	leaves = append(leaves, leaf)
+	var children []int
+	for _, leaf := range leaves {
+		children = append(children, db.writeLeaf(sqliteIndexLeaf, leaf, 0).page)
+	}
+
+	for len(children) > 1 {
+		// each node has one more child than separators, and the separator
+		// after each but the last node goes up to the level above
+		type indexNode struct {
+			children []int
+			seps     [][]byte
+		}
+		var nodes []indexNode
+		var upSeps [][]byte
+		node := indexNode{children: []int{children[0]}}
+		var nodeCells [][]byte
+		for k, sep := range seps {
+			cell := indexInteriorCell(node.children[len(node.children)-1], sep)
+			if fitsPage(0, 12, append(nodeCells, cell)) {
+				nodeCells = append(nodeCells, cell)
+				node.seps = append(node.seps, sep)
+				node.children = append(node.children, children[k+1])
+				continue
+			}
+			nodes = append(nodes, node)
+			upSeps = append(upSeps, sep)
+			node = indexNode{children: []int{children[k+1]}}
+			nodeCells = nil
+		}
+		// an interior page needs at least one cell
+		if len(node.seps) == 0 && len(nodes) > 0 {
+			prev := &nodes[len(nodes)-1]
+			last := len(prev.seps) - 1
+			node = indexNode{
+				children: []int{prev.children[last+1], node.children[0]},
+				seps:     [][]byte{upSeps[len(upSeps)-1]},
+			}
+			upSeps[len(upSeps)-1] = prev.seps[last]
+			prev.children = prev.children[:last+1]
+			prev.seps = prev.seps[:last]
+		}
+		nodes = append(nodes, node)
+		children = nil
+		for _, node := range nodes {
+			var cells [][]byte
+			for k, sep := range node.seps {
+				cells = append(cells, indexInteriorCell(node.children[k], sep))
+			}
+			num, page := db.allocate()
+			writeBTreePage(page, 0, sqliteIndexInterior, cells, node.children[len(node.children)-1])
+			children = append(children, num)
+		}
+		seps = upSeps
+	}
+	return children[0]
+}
+
+func indexInteriorCell(child int, entry []byte) []byte {
+	cell := binary.BigEndian.AppendUint32(nil, uint32(child))
+	return append(cell, entry...)
+}
+
+// compareSQLiteKeys orders index keys of integers and text, which are
+// compared by their bytes, as SQLite's default BINARY collation does.
+func compareSQLiteKeys(a, b []interface{}) int {
+	for k := range a {
+		switch x := a[k].(type) {
+		case int:
+			y := b[k].(int)
+			if x != y {
+				if x < y {
+					return -1
+				}
+				return 1
+			}
+		case string:
+			if c := strings.Compare(x, b[k].(string)); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
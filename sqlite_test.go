@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestPutVarint(t *testing.T) {
+	for _, c := range []struct {
+		v    uint64
+		want []byte
+	}{
+		{0, []byte{0x00}},
+		{127, []byte{0x7f}},
+		{128, []byte{0x81, 0x00}},
+		{240, []byte{0x81, 0x70}},
+		{16383, []byte{0xff, 0x7f}},
+		{16384, []byte{0x81, 0x80, 0x00}},
+		{1<<56 - 1, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}},
+		{1 << 56, []byte{0x80, 0xc0, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x00}},
+		{1<<64 - 1, bytes.Repeat([]byte{0xff}, 9)},
+	} {
+		if got := putVarint(nil, c.v); !bytes.Equal(got, c.want) {
+			t.Errorf("putVarint(%d) = % x, want % x", c.v, got, c.want)
+		}
+	}
+}
+
+func TestSQLiteRecord(t *testing.T) {
+	for _, c := range []struct {
+		values []interface{}
+		want   []byte
+	}{
+		{[]interface{}{nil, 1, "ab", 0.5}, []byte{0x05, 0x00, 0x09, 0x11, 0x07, 'a', 'b', 0x3f, 0xe0, 0, 0, 0, 0, 0, 0}},
+		{[]interface{}{0, -1, 300, 1 << 40}, []byte{0x05, 0x08, 0x01, 0x02, 0x05, 0xff, 0x01, 0x2c, 0x01, 0, 0, 0, 0, 0}},
+		{[]interface{}{"", math.NaN()}, []byte{0x03, 0x0d, 0x00}},
+	} {
+		if got := sqliteRecord(c.values); !bytes.Equal(got, c.want) {
+			t.Errorf("sqliteRecord(%v) = % x, want % x", c.values, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// writeSummary prints an overview of the tallied matrix for -summary: its
+// size, the total weight and Kish effective number of reads with
+// -weightcol, the fraction of cells that are 1 and the least and most
+// prevalent columns.
+func writeSummary(w io.Writer, t *Tally, names []string) {
+	fmt.Fprintf(w, "reads: %d\n", t.NumReads)
+	if t.Weights != nil {
+		fmt.Fprintf(w, "total weight: %g\n", t.Weights.sum)
+		fmt.Fprintf(w, "effective reads: %s\n", formatValue(t.Weights.effectiveN(), 'f', 2))
+	}
+	for k, source := range t.Sources {
+		fmt.Fprintf(w, "reads from '%s': %d\n", source, t.SourceReads[k])
+	}
+	if t.Multiplicities != nil {
+		// How many distinct rows occurred each number of times
+		var times []int
+		distinct := 0
+		for m, n := range t.Multiplicities {
+			times = append(times, m)
+			distinct += n
+		}
+		sort.Ints(times)
+		fmt.Fprintf(w, "distinct rows: %d\n", distinct)
+		for _, m := range times {
+			fmt.Fprintf(w, "multiplicity %d: %d rows\n", m, t.Multiplicities[m])
+		}
+	}
+	fmt.Fprintf(w, "columns: %d\n", len(names))
+	if args.SortCols {
+		// The input position of each column, for reproducing the order
+		positions := make([]string, len(t.Order))
+		for i, o := range t.Order {
+			positions[i] = strconv.Itoa(o + 1)
+		}
+		fmt.Fprintf(w, "column order: %s\n", strings.Join(positions, ","))
+	}
+	if len(names) == 0 {
+		return
+	}
+	ones := 0
+	minI, maxI := 0, 0
+	for i, m := range t.Marginals {
+		ones += m
+		if m < t.Marginals[minI] {
+			minI = i
+		}
+		if m > t.Marginals[maxI] {
+			maxI = i
+		}
+	}
+	n := float64(t.NumReads)
+	fmt.Fprintf(w, "density: %s\n", formatValue(float64(ones)/(n*float64(len(names))), 'f', 6))
+	fmt.Fprintf(w, "min marginal: %s = %s ; %d\n", names[minI], formatValue(float64(t.Marginals[minI])/n, 'f', 6), t.Marginals[minI])
+	fmt.Fprintf(w, "max marginal: %s = %s ; %d\n", names[maxI], formatValue(float64(t.Marginals[maxI])/n, 'f', 6), t.Marginals[maxI])
+}
@@ -0,0 +1,343 @@
+package main
+
+import "sort"
+
+// Tally accumulates the marginal and joint counts of the indicator variables
+// over all the reads seen so far.
+type Tally struct {
+	FieldNames []string
+	NumReads   int
+	Marginals  []int
+	Joints     [][]int
+	calcJoints bool
+	// Pairs lists the only pairs of columns whose joints are counted, when
+	// restricted by -pairs
+	Pairs [][2]int
+	// JointCols lists the only columns whose joints are counted, when
+	// restricted by -joint-columns, and Joints is then indexed by their
+	// positions in it
+	JointCols []int
+	merges    []merge
+	// Order gives the input position of each column after any reordering
+	Order []int
+	// Columns holds the value of every read for each column, and Reads the
+	// name of every read, when kept
+	Columns     []bitset
+	Reads       []string
+	keepColumns bool
+	// AnySet and AllSet count the reads that are 1 in any or all of the
+	// -any-of and -all-of columns, when tracked
+	AnySet *setTally
+	AllSet *setTally
+	// GivenSet counts the reads that are 1 in all of the -given columns,
+	// and in each column as well.
+	GivenSet *setTally
+	sets     []*setTally
+	// Weights accumulates the -weightcol weights, when tracked
+	Weights *weightTally
+	// Groups counts the reads of each -groupby group, when tracked
+	Groups *groupTally
+	// Missing counts the -missing-value cells of each column, when given
+	Missing []int
+	// Multiplicities counts the distinct rows seen each number of times,
+	// when collapsed by -multiplicity
+	Multiplicities map[int]int
+	// Sources lists the -inputs, when merged, and SourceReads the number of
+	// reads read from each
+	Sources     []string
+	SourceReads []int
+	// Label is the -label column the naive Bayes model predicts
+	Label int
+	// Target is the -target column of the -cpt and -mi-rank outputs, and
+	// Parents the -parents of the -cpt
+	Target      int
+	Parents     []int
+	nb          *nbModel
+	predictions []prediction
+	// predictInput holds the -predict-input reads to score, when given
+	predictInput *predictInput
+}
+
+func NewTally(fieldNames []string, calcJoints bool) *Tally {
+	numFields := len(fieldNames)
+	t := &Tally{
+		FieldNames: fieldNames,
+		Marginals:  make([]int, numFields),
+		calcJoints: calcJoints,
+		Order:      make([]int, numFields),
+	}
+	for i := range t.Order {
+		t.Order[i] = i
+	}
+	if calcJoints {
+		t.Joints = make([][]int, numFields)
+		for i := 0; i < numFields; i++ {
+			t.Joints[i] = make([]int, numFields)
+		}
+	}
+	return t
+}
+
+// KeepColumns makes the tally retain every read's values as column bitsets,
+// along with the read names, for the outputs that need more than counts. It
+// must be called before the first read is added.
+func (t *Tally) KeepColumns() {
+	t.keepColumns = true
+	t.Columns = make([]bitset, len(t.FieldNames))
+}
+
+// RestrictJoints makes the tally count the joints only among the given
+// columns, in increasing order, so that it holds just the square of their
+// number. It must be called before the first read is added.
+func (t *Tally) RestrictJoints(cols []int) {
+	t.calcJoints = true
+	t.JointCols = cols
+	t.Joints = make([][]int, len(cols))
+	for a := range t.Joints {
+		t.Joints[a] = make([]int, len(cols))
+	}
+}
+
+// TrackSet returns a new set of columns whose reads the tally will count.
+// It must be called before the first read is added.
+func (t *Tally) TrackSet(cols []int) *setTally {
+	st := &setTally{cols: cols}
+	t.sets = append(t.sets, st)
+	return st
+}
+
+// TrackGiven is like TrackSet, but the set also counts the reads that are
+// 1 in each column as well as in all of the set.
+func (t *Tally) TrackGiven(cols []int) *setTally {
+	st := t.TrackSet(cols)
+	st.given = make([]int, len(t.FieldNames))
+	return st
+}
+
+// Add tallies the indicator values of a single read.
+func (t *Tally) Add(read string, row []int) {
+	t.AddTimes(read, row, 1)
+}
+
+// AddTimes tallies the indicator values of a read seen the given number of
+// times, as if each had been added. The columns can only be kept of reads
+// seen once.
+func (t *Tally) AddTimes(read string, row []int, times int) {
+	if t.Multiplicities != nil {
+		t.Multiplicities[times]++
+	}
+	for _, st := range t.sets {
+		st.add(row, times)
+	}
+	if t.keepColumns {
+		t.Reads = append(t.Reads, read)
+		for i := range t.Columns {
+			if t.NumReads%64 == 0 {
+				t.Columns[i] = append(t.Columns[i], 0)
+			}
+			if row[i] == 1 {
+				t.Columns[i].set(t.NumReads)
+			}
+		}
+	}
+	for i := range t.FieldNames {
+		t.Marginals[i] += row[i] * times
+	}
+	// joint counts
+	if t.calcJoints && t.Pairs != nil {
+		for _, pair := range t.Pairs {
+			i, j := pair[0], pair[1]
+			if row[i]*row[j] == 1 {
+				t.Joints[i][j] += times
+				if i != j {
+					t.Joints[j][i] += times
+				}
+			}
+		}
+	} else if t.calcJoints && t.JointCols != nil {
+		for a, i := range t.JointCols {
+			if row[i] != 1 {
+				continue
+			}
+			for b, j := range t.JointCols {
+				if row[j] == 1 {
+					t.Joints[a][b] += times
+				}
+			}
+		}
+	} else if t.calcJoints {
+		for i := range t.FieldNames {
+			for j := range t.FieldNames {
+				if row[i]*row[j] == 1 {
+					t.Joints[i][j] += times
+				}
+			}
+		}
+	}
+	t.NumReads += times
+}
+
+// Permute reorders the columns so that column i is the one previously at
+// position order[i].
+func (t *Tally) Permute(order []int) {
+	fieldNames := make([]string, len(order))
+	marginals := make([]int, len(order))
+	prevOrder := make([]int, len(order))
+	for i, o := range order {
+		fieldNames[i] = t.FieldNames[o]
+		marginals[i] = t.Marginals[o]
+		prevOrder[i] = t.Order[o]
+	}
+	if t.Joints != nil && t.JointCols == nil {
+		joints := make([][]int, len(order))
+		for i, oi := range order {
+			joints[i] = make([]int, len(order))
+			for j, oj := range order {
+				joints[i][j] = t.Joints[oi][oj]
+			}
+		}
+		t.Joints = joints
+	}
+	if t.Columns != nil {
+		columns := make([]bitset, len(order))
+		for i, o := range order {
+			columns[i] = t.Columns[o]
+		}
+		t.Columns = columns
+	}
+	position := make([]int, len(order))
+	for i, o := range order {
+		position[o] = i
+	}
+	for k, pair := range t.Pairs {
+		t.Pairs[k] = [2]int{position[pair[0]], position[pair[1]]}
+	}
+	if t.JointCols != nil {
+		t.permuteJointCols(position)
+	}
+	for _, st := range t.sets {
+		for k, c := range st.cols {
+			st.cols[k] = position[c]
+		}
+		if st.given != nil {
+			given := make([]int, len(order))
+			for i, o := range order {
+				given[i] = st.given[o]
+			}
+			st.given = given
+		}
+	}
+	if t.Weights != nil {
+		weighted := make([]float64, len(order))
+		for i, o := range order {
+			weighted[i] = t.Weights.marginals[o]
+		}
+		t.Weights.marginals = weighted
+	}
+	if t.Groups != nil {
+		for g, groupMarginals := range t.Groups.marginals {
+			permuted := make([]int, len(order))
+			for i, o := range order {
+				permuted[i] = groupMarginals[o]
+			}
+			t.Groups.marginals[g] = permuted
+		}
+	}
+	if t.Missing != nil {
+		missing := make([]int, len(order))
+		for i, o := range order {
+			missing[i] = t.Missing[o]
+		}
+		t.Missing = missing
+	}
+	t.FieldNames = fieldNames
+	t.Marginals = marginals
+	t.Order = prevOrder
+	t.Label = position[t.Label]
+	t.Target = position[t.Target]
+	for k, p := range t.Parents {
+		t.Parents[k] = position[p]
+	}
+	t.resetCache()
+}
+
+// permuteJointCols moves the -joint-columns to the columns' new positions,
+// reordering their joints to keep them in increasing order.
+func (t *Tally) permuteJointCols(position []int) {
+	subset := make([]int, len(t.JointCols))
+	for a := range subset {
+		subset[a] = a
+	}
+	sort.Slice(subset, func(x, y int) bool {
+		return position[t.JointCols[subset[x]]] < position[t.JointCols[subset[y]]]
+	})
+	cols := make([]int, len(subset))
+	joints := make([][]int, len(subset))
+	for a, oa := range subset {
+		cols[a] = position[t.JointCols[oa]]
+		joints[a] = make([]int, len(subset))
+		for b, ob := range subset {
+			joints[a][b] = t.Joints[oa][ob]
+		}
+	}
+	t.JointCols = cols
+	t.Joints = joints
+}
+
+// jointView returns a tally of just the -joint-columns, sharing the counts
+// of the full one, and their names, for the outputs that need the joints.
+func (t *Tally) jointView(names []string) (*Tally, []string) {
+	k := len(t.JointCols)
+	view := &Tally{
+		FieldNames:  make([]string, k),
+		NumReads:    t.NumReads,
+		Marginals:   make([]int, k),
+		Joints:      t.Joints,
+		calcJoints:  true,
+		Order:       make([]int, k),
+		Reads:       t.Reads,
+		keepColumns: t.keepColumns,
+		AnySet:      t.AnySet,
+		AllSet:      t.AllSet,
+	}
+	if t.Columns != nil {
+		view.Columns = make([]bitset, k)
+	}
+	if t.Missing != nil {
+		view.Missing = make([]int, k)
+	}
+	viewNames := make([]string, k)
+	for a, i := range t.JointCols {
+		view.FieldNames[a] = t.FieldNames[i]
+		view.Marginals[a] = t.Marginals[i]
+		view.Order[a] = t.Order[i]
+		if view.Columns != nil {
+			view.Columns[a] = t.Columns[i]
+		}
+		if view.Missing != nil {
+			view.Missing[a] = t.Missing[i]
+		}
+		if i == t.Target {
+			view.Target = a
+		}
+		viewNames[a] = names[i]
+	}
+	return view, viewNames
+}
+
+// resetCache discards the results computed from the counts so far, so that
+// they are recomputed when next needed.
+func (t *Tally) resetCache() {
+	t.merges = nil
+	t.nb = nil
+	t.predictions = nil
+}
+
+// NaiveBayes returns the naive Bayes model for the label column, training
+// it the first time it is needed.
+func (t *Tally) NaiveBayes() *nbModel {
+	if t.nb == nil {
+		t.nb = trainNaiveBayes(t, t.Label)
+	}
+	return t.nb
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// BenchmarkTallyAdd measures tallying the joint counts alone, the phase that
+// -benchmark reports as joints.
+func BenchmarkTallyAdd(b *testing.B) {
+	wr, err := newWideReader(bytes.NewReader(wideInput(1000, 100)), 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var rows [][]int
+	for wr.Scan() {
+		rows = append(rows, append([]int(nil), wr.Row()...))
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		tally := NewTally(wr.Fields(), true)
+		for _, row := range rows {
+			tally.Add("", row)
+		}
+	}
+}
+
+// generatedRows parses a matrix from the -generate generator, always drawn
+// with the same seed, into a row of values per read.
+func generatedRows(b *testing.B, numReads, numFields int, density float64) ([]string, [][]int) {
+	b.Helper()
+	random = rand.New(rand.NewSource(1))
+	var buf bytes.Buffer
+	generateMatrix(&buf, numReads, numFields, density, nil)
+	wr, err := newWideReader(&buf, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var rows [][]int
+	for wr.Scan() {
+		rows = append(rows, append([]int(nil), wr.Row()...))
+	}
+	if err := wr.Err(); err != nil {
+		b.Fatal(err)
+	}
+	return wr.Fields(), rows
+}
+
+// BenchmarkJoints measures accumulating the joint counts of every pair of
+// columns, one read at a time, for matrices of different sizes and
+// densities. An op is the whole matrix, so ns/op should grow with the
+// reads times the square of the columns.
+func BenchmarkJoints(b *testing.B) {
+	for _, numReads := range []int{1000, 10000} {
+		for _, numFields := range []int{10, 100} {
+			for _, density := range []float64{0.05, 0.5} {
+				fields, rows := generatedRows(b, numReads, numFields, density)
+				name := fmt.Sprintf("reads=%d/columns=%d/density=%g", numReads, numFields, density)
+				b.Run(name, func(b *testing.B) {
+					b.ReportAllocs()
+					for n := 0; n < b.N; n++ {
+						tally := NewTally(fields, true)
+						for _, row := range rows {
+							tally.Add("", row)
+						}
+					}
+				})
+			}
+		}
+	}
+}
+
+// BenchmarkBitsetJoints measures counting the joints of every pair of
+// columns from bitsets of the columns, as the outputs that keep the columns
+// do, for comparison with BenchmarkJoints. The columns are kept before the
+// timing starts, so only the counting is measured.
+func BenchmarkBitsetJoints(b *testing.B) {
+	for _, numReads := range []int{1000, 10000} {
+		for _, numFields := range []int{10, 100} {
+			fields, rows := generatedRows(b, numReads, numFields, 0.5)
+			tally := NewTally(fields, false)
+			tally.KeepColumns()
+			for _, row := range rows {
+				tally.Add("", row)
+			}
+			b.Run(fmt.Sprintf("reads=%d/columns=%d", numReads, numFields), func(b *testing.B) {
+				b.ReportAllocs()
+				joints := make([][]int, numFields)
+				for i := range joints {
+					joints[i] = make([]int, numFields)
+				}
+				b.ResetTimer()
+				for n := 0; n < b.N; n++ {
+					for i := range joints {
+						for j := range joints[i] {
+							joints[i][j] = andCount(tally.Columns[i], tally.Columns[j])
+						}
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// normalCDF is the standard normal distribution function.
+func normalCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+// normalQuantile is the inverse of normalCDF.
+func normalQuantile(p float64) float64 {
+	return -math.Sqrt2 * math.Erfcinv(2*p)
+}
+
+// bivariateNormalCDF returns P(X < a, Y < b) for standard normals of
+// correlation rho, by Sheppard's formula: the independent probability plus
+// an integral over the angle asin(rho), whose integrand is smooth and
+// bounded so that Simpson's rule converges quickly even as |rho| nears 1.
+func bivariateNormalCDF(a, b, rho float64) float64 {
+	const intervals = 200
+	end := math.Asin(rho)
+	f := func(theta float64) float64 {
+		c := math.Cos(theta)
+		if c == 0 {
+			return 0
+		}
+		return math.Exp(-(a*a + b*b - 2*a*b*math.Sin(theta)) / (2 * c * c))
+	}
+	h := end / intervals
+	sum := f(0) + f(end)
+	for k := 1; k < intervals; k++ {
+		weight := 2.0
+		if k%2 == 1 {
+			weight = 4
+		}
+		sum += weight * f(float64(k)*h)
+	}
+	p := normalCDF(a)*normalCDF(b) + sum*h/3/(2*math.Pi)
+	return math.Min(math.Max(p, 0), 1)
+}
+
+// tetrachoric estimates the correlation of two latent standard normals that
+// give a 2x2 table when cut at thresholds. The thresholds are fixed by the
+// marginals, and the correlation is the one maximizing the multinomial
+// likelihood of the table, found by golden-section search. It did not
+// converge when the maximum lies on the edge of the range, as it does when
+// a cell is empty, or when either indicator is constant, which gives NaN.
+func tetrachoric(n11, n10, n01, n00 int) (rho float64, converged bool) {
+	n := float64(n11 + n10 + n01 + n00)
+	pa, pb := float64(n11+n10)/n, float64(n11+n01)/n
+	if !(pa > 0 && pa < 1 && pb > 0 && pb < 1) {
+		return math.NaN(), false
+	}
+	// Reads are 1 where the latent variable is above its threshold
+	h, k := normalQuantile(1-pa), normalQuantile(1-pb)
+	cells := [4]float64{float64(n11), float64(n10), float64(n01), float64(n00)}
+	logLikelihood := func(r float64) float64 {
+		p00 := bivariateNormalCDF(h, k, r)
+		p01 := normalCDF(h) - p00
+		p10 := normalCDF(k) - p00
+		p11 := 1 - p00 - p01 - p10
+		ll := 0.0
+		for c, p := range [4]float64{p11, p10, p01, p00} {
+			if cells[c] > 0 {
+				ll += cells[c] * math.Log(math.Max(p, 1e-300))
+			}
+		}
+		return ll
+	}
+	const (
+		limit     = 1 - 1e-6
+		tolerance = 1e-9
+	)
+	ratio := (math.Sqrt(5) - 1) / 2
+	lo, hi := -limit, limit
+	x1, x2 := hi-ratio*(hi-lo), lo+ratio*(hi-lo)
+	f1, f2 := logLikelihood(x1), logLikelihood(x2)
+	for hi-lo > tolerance {
+		if f1 < f2 {
+			lo, x1, f1 = x1, x2, f2
+			x2 = lo + ratio*(hi-lo)
+			f2 = logLikelihood(x2)
+		} else {
+			hi, x2, f2 = x2, x1, f1
+			x1 = hi - ratio*(hi-lo)
+			f1 = logLikelihood(x1)
+		}
+	}
+	rho = (lo + hi) / 2
+	converged = math.Abs(rho) < limit-1e-4
+	for _, cell := range cells {
+		// The likelihood then keeps growing toward a correlation of 1 or -1
+		converged = converged && cell > 0
+	}
+	return rho, converged
+}
+
+// writeTetrachoric prints the tetrachoric correlation of every unordered pair
+// of indicators along with the cells of its contingency table, and whether
+// the estimate converged.
+func writeTetrachoric(w io.Writer, t *Tally, names []string) {
+	for i, iName := range names {
+		for j := i + 1; j < len(names); j++ {
+			n11, n10, n01, n00 := t.Contingency(i, j)
+			rho, converged := tetrachoric(n11, n10, n01, n00)
+			status := "converged"
+			if !converged {
+				status = "not_converged"
+			}
+			fmt.Fprintf(w, "tetrachoric( %s , %s ) = %s ; %d , %d , %d , %d ; %s\n",
+				iName, names[j], formatValue(rho, 'f', 8), n11, n10, n01, n00, status)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// TestBivariateNormalCDF checks Sheppard's formula against the orthant
+// probabilities of standard normals, which have a closed form.
+func TestBivariateNormalCDF(t *testing.T) {
+	for _, rho := range []float64{-0.999, -0.9, -0.5, 0, 0.3, 0.8, 0.999} {
+		want := 0.25 + math.Asin(rho)/(2*math.Pi)
+		if got := bivariateNormalCDF(0, 0, rho); math.Abs(got-want) > 1e-9 {
+			t.Errorf("bivariateNormalCDF(0, 0, %g) = %.10f, want %.10f", rho, got, want)
+		}
+	}
+	if got, want := bivariateNormalCDF(1, -0.5, 0), normalCDF(1)*normalCDF(-0.5); math.Abs(got-want) > 1e-12 {
+		t.Errorf("bivariateNormalCDF(1, -0.5, 0) = %.12f, want %.12f", got, want)
+	}
+}
+
+// TestTetrachoric checks the estimates for tables where both indicators
+// are split evenly, whose tetrachoric correlation is Pearson's cos(pi d)
+// of the fraction d of discordant reads, and for uneven ones against
+// estimates found by integrating the bivariate normal density directly.
+// A table with an empty cell has its maximum on the edge of the range,
+// which the search only gets near, as the likelihood flattens there.
+func TestTetrachoric(t *testing.T) {
+	for _, c := range []struct {
+		n11, n10, n01, n00 int
+		want, tolerance    float64
+		converged          bool
+	}{
+		{40, 10, 10, 40, math.Cos(math.Pi * 0.2), 1e-6, true},
+		{10, 40, 40, 10, math.Cos(math.Pi * 0.8), 1e-6, true},
+		{25, 25, 25, 25, 0, 1e-6, true},
+		{45, 5, 5, 45, math.Cos(math.Pi * 0.1), 1e-6, true},
+		{30, 10, 20, 40, 0.60707279, 1e-6, true},
+		{5, 15, 10, 70, 0.27301716, 1e-6, true},
+		{60, 5, 25, 10, 0.49719693, 1e-6, true},
+		{20, 0, 10, 70, 1, 0.01, false},
+		{0, 30, 30, 40, -1, 0.01, false},
+		{30, 20, 50, 0, -1, 0.01, false},
+	} {
+		rho, converged := tetrachoric(c.n11, c.n10, c.n01, c.n00)
+		if math.Abs(rho-c.want) > c.tolerance || converged != c.converged {
+			t.Errorf("tetrachoric(%d, %d, %d, %d) = %.8f, %t, want %.8f, %t",
+				c.n11, c.n10, c.n01, c.n00, rho, converged, c.want, c.converged)
+		}
+	}
+	if rho, converged := tetrachoric(10, 0, 20, 0); !math.IsNaN(rho) || converged {
+		t.Errorf("tetrachoric of a constant column = %g, %t, want NaN, false", rho, converged)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// columnThresholds maps columns to the -thresholds cutoff of their values,
+// and defaultThreshold is the -threshold of every other column. A column
+// with a NaN threshold has its values parsed as 0/1 indicators.
+var (
+	columnThresholds map[string]float64
+	defaultThreshold = math.NaN()
+)
+
+// readThresholds reads a two-column TSV mapping column names to the value
+// at or above which each one is taken to be 1.
+func readThresholds(path string) (map[string]float64, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open thresholds file '%s': %v", path, err)
+	}
+	defer fp.Close()
+	thresholds := make(map[string]float64)
+	scanner := bufio.NewScanner(fp)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("expected line %d of '%s' to have 2 fields", lineNum, path)
+		}
+		if _, ok := thresholds[fields[0]]; ok {
+			return nil, fmt.Errorf("column '%s' is given more than one threshold in '%s'", fields[0], path)
+		}
+		t, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil || math.IsNaN(t) {
+			return nil, fmt.Errorf("invalid threshold '%s' on line %d of '%s'", fields[1], lineNum, path)
+		}
+		thresholds[fields[0]] = t
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return thresholds, nil
+}
+
+// checkThresholds checks that every column given a threshold is one of the
+// input's columns.
+func checkThresholds(fieldNames []string) error {
+	known := make(map[string]bool)
+	for _, name := range fieldNames {
+		known[name] = true
+	}
+	for name := range columnThresholds {
+		if !known[name] {
+			return fmt.Errorf("thresholds file '%s' names unknown column '%s'", args.Thresholds, name)
+		}
+	}
+	return nil
+}
+
+// columnThreshold returns the threshold of one column.
+func columnThreshold(name string) float64 {
+	if t, ok := columnThresholds[name]; ok {
+		return t
+	}
+	return defaultThreshold
+}
+
+// thresholdsFor returns the threshold of each of the columns.
+func thresholdsFor(names []string) []float64 {
+	thresholds := make([]float64, len(names))
+	for i, name := range names {
+		thresholds[i] = columnThreshold(name)
+	}
+	return thresholds
+}
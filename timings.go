@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// phaseTimes accumulates the wall-clock time spent in each phase of a run
+// for -timings.
+type phaseTimes struct {
+	parse      time.Duration
+	accumulate time.Duration
+	write      time.Duration
+}
+
+var timings phaseTimes
+
+// lap adds the time since mark to a phase and returns the new mark.
+func lap(phase *time.Duration, mark time.Time) time.Time {
+	now := time.Now()
+	*phase += now.Sub(mark)
+	return now
+}
+
+// writeTimings prints the time spent in each phase for -timings. Parsing
+// includes reading the input, and writing includes any -flush-interval
+// snapshots and -checkpoint saves.
+func writeTimings(w io.Writer) {
+	fmt.Fprintf(w, "parse: %v\n", timings.parse)
+	fmt.Fprintf(w, "accumulate: %v\n", timings.accumulate)
+	fmt.Fprintf(w, "write: %v\n", timings.write)
+}
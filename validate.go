@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+)
+
+// validate reads the whole input, checking that it is well-formed, and
+// reports its size. It exits with a non-zero status if there is a problem.
+func validate() {
+	in, err := openInput()
+	if err != nil {
+		fatal(err)
+	}
+	defer in.Close()
+	reader, err := openReader(in)
+	if err != nil {
+		fatal("invalid input:", err)
+	}
+	numReads := 0
+	for reader.Scan() {
+		numReads++
+	}
+	if err := reader.Err(); err != nil {
+		errorf("invalid input: %v\n", err)
+		infof("read %d valid reads of %d columns before the problem\n", numReads, len(reader.Fields()))
+		os.Exit(1)
+	}
+	if reader.Fields() == nil {
+		fatal("invalid input: there is no header")
+	}
+	if badRows.report() > 0 {
+		infof("read %d valid reads of %d columns\n", numReads, len(reader.Fields()))
+		os.Exit(1)
+	}
+	infow(logFields{"reads": numReads, "columns": len(reader.Fields())},
+		"input is valid: %d reads, %d columns\n", numReads, len(reader.Fields()))
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+)
+
+// fileChanged reports whether a file's size or modification time differs
+// between two observations of it.
+func fileChanged(a, b os.FileInfo) bool {
+	return a.Size() != b.Size() || !a.ModTime().Equal(b.ModTime())
+}
+
+// watchInput computes every output from the -input file and then polls it
+// every -watch-interval, recomputing the outputs whenever it changes. A
+// change is only acted upon once the file has stayed the same for a whole
+// interval, so that a file in the middle of being rewritten is not read.
+// Problems with the input are logged and the watch carries on, so the
+// outputs keep the last good results until the input is fixed. The watch
+// ends when the context is cancelled.
+func watchInput(ctx context.Context, req requirements, aliases map[string]string) {
+	var last, pending os.FileInfo
+	for ctx.Err() == nil {
+		fi, err := os.Stat(args.Input)
+		if err != nil {
+			warnf("failed to check input '%s': %v\n", args.Input, err)
+		} else if last == nil || fileChanged(fi, last) {
+			if pending != nil && !fileChanged(fi, pending) {
+				recompute(ctx, req, aliases)
+				last, pending = fi, nil
+			} else {
+				pending = fi
+			}
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(args.WatchInterval):
+		}
+	}
+	infof("stopped watching '%s'\n", args.Input)
+}
+
+// recompute rereads the input and replaces every output with the results.
+func recompute(ctx context.Context, req requirements, aliases map[string]string) {
+	start := time.Now()
+	tally, err := tallyInput(ctx, req, aliases)
+	if errors.Is(err, errInterrupted) {
+		return
+	} else if err != nil {
+		errorf("failed to read input '%s': %v\n", args.Input, err)
+		return
+	}
+	if tally.Groups != nil {
+		if err := checkGroups(tally); err != nil {
+			errorf("%v\n", err)
+			return
+		}
+	}
+	if err := writeSnapshot(tally, displayNames(tally.FieldNames, aliases)); err != nil {
+		errorf("%v\n", err)
+		return
+	}
+	infow(logFields{"rows": tally.NumReads, "seconds": time.Since(start).Seconds()},
+		"recomputed outputs from %d rows in %v\n", tally.NumReads, since(start))
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// weightTally accumulates the -weightcol weights of the reads, along with
+// the total weight of the reads where each indicator is 1.
+type weightTally struct {
+	sum       float64
+	sumSq     float64
+	marginals []float64
+}
+
+// TrackWeights makes the tally accumulate the weights of the reads given
+// to AddWeight. It must be called before the first read is added.
+func (t *Tally) TrackWeights() {
+	t.Weights = &weightTally{marginals: make([]float64, len(t.FieldNames))}
+}
+
+// AddWeight tallies the weight of a read.
+func (t *Tally) AddWeight(row []int, weight float64) {
+	wt := t.Weights
+	wt.sum += weight
+	wt.sumSq += weight * weight
+	for i, v := range row {
+		if v == 1 {
+			wt.marginals[i] += weight
+		}
+	}
+}
+
+// effectiveN returns Kish's effective sample size (Σw)² / Σw², the number
+// of unweighted reads that would estimate a proportion as precisely as the
+// weighted ones do.
+func (wt *weightTally) effectiveN() float64 {
+	if wt.sumSq == 0 {
+		return 0
+	}
+	return wt.sum * wt.sum / wt.sumSq
+}
+
+// writeWeightedMarginals prints the weighted marginal probability of each
+// indicator, along with its standard error, which uses the effective sample
+// size rather than the total weight so that unequal weights don't overstate
+// its precision.
+func writeWeightedMarginals(w io.Writer, t *Tally, names []string) {
+	wt := t.Weights
+	n := wt.effectiveN()
+	for i, name := range names {
+		p := math.NaN()
+		if wt.sum > 0 {
+			p = wt.marginals[i] / wt.sum
+		}
+		se := math.Sqrt(p * (1 - p) / n)
+		fmt.Fprintf(w, "P( %s ) = %s ; %s , %s\n", name, formatProb(p, 6), formatProb(se, 6), formatValue(n, 'f', 2))
+	}
+}